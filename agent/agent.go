@@ -0,0 +1,352 @@
+// Package agent implements podx agent, a daemon that keeps unlocked age
+// identities in memory and unwraps file keys for the CLI over a Unix socket,
+// so passphrase-protected keys are only unlocked once.
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/hades/podx/crypto"
+)
+
+// SocketEnv names the environment variable holding the agent socket path
+const SocketEnv = "PODX_AGENT_SOCK"
+
+// request is sent by the client, one per connection
+type request struct {
+	Op      string        `json:"op"` // unwrap, add, lock, status or stop
+	Stanzas []*age.Stanza `json:"stanzas,omitempty"`
+	Data    []byte        `json:"data,omitempty"` // Identities (add)
+}
+
+// response is the agent's answer to a request
+type response struct {
+	Error   string  `json:"error,omitempty"`
+	NoMatch bool    `json:"no_match,omitempty"`
+	FileKey []byte  `json:"file_key,omitempty"`
+	Status  *Status `json:"status,omitempty"`
+}
+
+// Status describes a running agent
+type Status struct {
+	PID        int           `json:"pid"`
+	Identities int           `json:"identities"`
+	Locked     bool          `json:"locked"`
+	TTL        time.Duration `json:"ttl"`
+	IdleSince  time.Time     `json:"idle_since"`
+}
+
+// DefaultSocket returns $XDG_RUNTIME_DIR/podx/agent.sock, or a per-user
+// directory in the temp dir when XDG_RUNTIME_DIR is not set
+func DefaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "podx", "agent.sock")
+	}
+	return filepath.Join(os.TempDir(), "podx-"+strconv.Itoa(os.Getuid()), "agent.sock")
+}
+
+// connTimeout bounds how long one client may hold a connection, so a stuck
+// client can't keep a handler (and its request data) around forever
+const connTimeout = 10 * time.Second
+
+// server holds the unlocked identity data in buffers it owns. Identities are
+// parsed from them for each unwrap, so the only long-lived copy of the keys
+// is in those buffers, which locking overwrites before dropping them.
+type server struct {
+	mu         sync.Mutex
+	keys       [][]byte // Unlocked identity data, one buffer per add
+	identities int      // Number of identities in keys
+	ttl        time.Duration
+	lastUsed   time.Time
+	listener   net.Listener
+}
+
+// Serve listens on socket and serves requests until stopped. identities is
+// unlocked identity data (see crypto.UnlockIdentities); the server takes
+// ownership of the buffer and wipes it when locked. With a non-zero ttl, the
+// identities are dropped after being idle that long.
+func Serve(socket string, identities []byte, ttl time.Duration) error {
+	s := &server{ttl: ttl, lastUsed: time.Now()}
+	if err := s.add(identities); err != nil {
+		return err
+	}
+
+	// The directory keeps other users away from the socket
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if err := checkSocketDir(filepath.Dir(socket)); err != nil {
+		return err
+	}
+	if Running(socket) {
+		return fmt.Errorf("an agent is already running on %s", socket)
+	}
+	os.Remove(socket)
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	defer os.Remove(socket)
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	s.listener = l
+
+	if ttl > 0 {
+		go s.expire()
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// expire drops the identities once the agent has been idle for the TTL
+func (s *server) expire() {
+	for {
+		s.mu.Lock()
+		wait := time.Until(s.lastUsed.Add(s.ttl))
+		if wait <= 0 {
+			s.wipe()
+		}
+		s.mu.Unlock()
+
+		if wait <= 0 {
+			wait = s.ttl
+		}
+		time.Sleep(wait)
+	}
+}
+
+// add checks and keeps a buffer of identity data; the caller holds s.mu or
+// has not started serving yet
+func (s *server) add(data []byte) error {
+	ids, err := crypto.LoadIdentities(string(data))
+	if err != nil {
+		clear(data)
+		return err
+	}
+	s.keys = append(s.keys, data)
+	s.identities += len(ids)
+	s.lastUsed = time.Now()
+	return nil
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(connTimeout))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(response{Error: "invalid request: " + err.Error()})
+		return
+	}
+
+	resp := s.do(&req)
+	json.NewEncoder(conn).Encode(resp)
+
+	if req.Op == "stop" {
+		s.listener.Close()
+	}
+}
+
+func (s *server) do(req *request) response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Op {
+	case "unwrap":
+		if len(s.keys) == 0 {
+			return response{Error: "agent is locked", NoMatch: true}
+		}
+		s.lastUsed = time.Now()
+		return s.unwrap(req.Stanzas)
+
+	case "add":
+		if err := s.add(req.Data); err != nil {
+			return response{Error: err.Error()}
+		}
+		return response{Status: s.status()}
+
+	case "lock", "stop":
+		s.wipe()
+		return response{Status: s.status()}
+
+	case "status":
+		return response{Status: s.status()}
+
+	default:
+		return response{Error: fmt.Sprintf("unknown operation '%s'", req.Op)}
+	}
+}
+
+func (s *server) unwrap(stanzas []*age.Stanza) response {
+	for _, data := range s.keys {
+		ids, err := crypto.LoadIdentities(string(data))
+		if err != nil {
+			return response{Error: err.Error()}
+		}
+		for _, id := range ids {
+			fileKey, err := id.Unwrap(stanzas)
+			if errors.Is(err, age.ErrIncorrectIdentity) {
+				continue
+			}
+			if err != nil {
+				return response{Error: err.Error()}
+			}
+			return response{FileKey: fileKey}
+		}
+	}
+	return response{Error: "no identity matched", NoMatch: true}
+}
+
+// wipe overwrites the identity data and drops it; the caller holds s.mu
+func (s *server) wipe() {
+	for _, data := range s.keys {
+		clear(data)
+	}
+	s.keys = nil
+	s.identities = 0
+}
+
+func (s *server) status() *Status {
+	return &Status{
+		PID:        os.Getpid(),
+		Identities: s.identities,
+		Locked:     len(s.keys) == 0,
+		TTL:        s.ttl,
+		IdleSince:  s.lastUsed,
+	}
+}
+
+// call sends one request to the agent listening on socket
+func call(socket string, req request) (*response, error) {
+	conn, err := net.DialTimeout("unix", socket, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("podx agent not reachable: %w", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid response from podx agent: %w", err)
+	}
+	return &resp, nil
+}
+
+// do sends a request and turns an error response into an error
+func do(socket string, req request) (*response, error) {
+	resp, err := call(socket, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("podx agent: %s", resp.Error)
+	}
+	return resp, nil
+}
+
+// Running reports whether an agent answers on socket
+func Running(socket string) bool {
+	_, err := do(socket, request{Op: "status"})
+	return err == nil
+}
+
+// GetStatus returns the status of the agent on socket
+func GetStatus(socket string) (*Status, error) {
+	resp, err := do(socket, request{Op: "status"})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+// Add sends unlocked identities (see crypto.UnlockIdentities) to the agent
+func Add(socket, identities string) (*Status, error) {
+	resp, err := do(socket, request{Op: "add", Data: []byte(identities)})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+// Lock makes the agent drop its identities
+func Lock(socket string) error {
+	_, err := do(socket, request{Op: "lock"})
+	return err
+}
+
+// Stop makes the agent drop its identities and exit
+func Stop(socket string) error {
+	_, err := do(socket, request{Op: "stop"})
+	return err
+}
+
+// identity forwards unwrap requests to the agent, so the private keys never
+// leave it
+type identity struct {
+	socket string
+}
+
+// NewIdentity returns an age identity backed by the agent on socket. An
+// unreachable or locked agent behaves like an identity that doesn't match,
+// so the CLI falls back to its own keys.
+func NewIdentity(socket string) age.Identity {
+	return &identity{socket: socket}
+}
+
+func (i *identity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	resp, err := call(i.socket, request{Op: "unwrap", Stanzas: stanzas})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", age.ErrIncorrectIdentity, err)
+	}
+	if resp.NoMatch {
+		return nil, fmt.Errorf("%w: podx agent: %s", age.ErrIncorrectIdentity, resp.Error)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("podx agent: %s", resp.Error)
+	}
+	return resp.FileKey, nil
+}
+
+// Spawn starts the podx binary with args (normally "agent serve ...") as a
+// background process detached from the terminal, passing identities on its
+// stdin so they never appear in the process arguments or environment
+func Spawn(args []string, identities string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = strings.NewReader(identities)
+	cmd.SysProcAttr = detached()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start agent: %w", err)
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/hades/podx/crypto"
+)
+
+// encryptTo wraps a file key to recipient and returns the header stanzas
+func encryptTo(t *testing.T, recipient string) []*age.Stanza {
+	t.Helper()
+	r, err := crypto.ParseRecipient(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stanzas, err := r.Wrap(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stanzas
+}
+
+func TestWipe(t *testing.T) {
+	identity, recipient, err := crypto.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("# test\n" + identity + "\n")
+	stanzas := encryptTo(t, recipient)
+
+	s := &server{}
+	if err := s.add(data); err != nil {
+		t.Fatal(err)
+	}
+	if resp := s.do(&request{Op: "unwrap", Stanzas: stanzas}); resp.Error != "" {
+		t.Fatalf("unwrap before wipe: %s", resp.Error)
+	}
+	if st := s.status(); st.Identities != 1 || st.Locked {
+		t.Errorf("status = %+v", st)
+	}
+
+	s.do(&request{Op: "lock"})
+	if !bytes.Equal(data, make([]byte, len(data))) {
+		t.Errorf("key buffer not wiped: %q", data)
+	}
+	resp := s.do(&request{Op: "unwrap", Stanzas: stanzas})
+	if !resp.NoMatch {
+		t.Errorf("locked agent unwrapped: %+v", resp)
+	}
+	if st := s.status(); st.Identities != 0 || !st.Locked {
+		t.Errorf("status after lock = %+v", st)
+	}
+}
+
+func TestAddInvalidWipesData(t *testing.T) {
+	data := []byte("AGE-SECRET-KEY-1NOTAVALIDKEY\n")
+	s := &server{}
+	if err := s.add(data); err == nil {
+		t.Fatal("expected an error")
+	}
+	if !bytes.Equal(data, make([]byte, len(data))) {
+		t.Errorf("rejected data not wiped: %q", data)
+	}
+}
+
+func TestServe(t *testing.T) {
+	identity, recipient, err := crypto.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+
+	done := make(chan error, 1)
+	go func() { done <- Serve(socket, []byte(identity+"\n"), 0) }()
+	for i := 0; !Running(socket); i++ {
+		if i == 100 {
+			t.Fatal("agent did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var buf bytes.Buffer
+	r, _ := crypto.ParseRecipient(recipient)
+	w, err := age.Encrypt(&buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "secret")
+	w.Close()
+
+	out, err := age.Decrypt(bytes.NewReader(buf.Bytes()), NewIdentity(socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, _ := io.ReadAll(out); string(plaintext) != "secret" {
+		t.Errorf("got %q", plaintext)
+	}
+
+	if err := Stop(socket); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not stop")
+	}
+}
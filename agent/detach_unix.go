@@ -0,0 +1,10 @@
+//go:build !windows
+
+package agent
+
+import "syscall"
+
+// detached runs the agent in its own session, so it survives the shell
+func detached() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
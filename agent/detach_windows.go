@@ -0,0 +1,12 @@
+//go:build windows
+
+package agent
+
+import "syscall"
+
+const detachedProcess = 0x00000008
+
+// detached runs the agent without a console, so it survives the shell
+func detached() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess}
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package agent
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkSocketDir makes sure dir is a directory. Access is controlled by the
+// ACLs of the user's profile, which Unix permission bits don't describe.
+func checkSocketDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("socket directory %s is not a directory", dir)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Package config locates the podx user config directory and resolves
+// per-user and per-project defaults.
+//
+// Settings are resolved in this order, the first one set wins: command-line
+// flags, environment variables, the project's .podx.yaml (defaults:) and the
+// user's config.yaml.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hades/podx/crypto"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the user config file in Dir
+const FileName = "config.yaml"
+
+// Settings are defaults that the user config, the project config or the
+// environment can set
+type Settings struct {
+	Identity    string `yaml:"identity,omitempty"`     // Identity file, tried after -i and PODX_AGE_KEY_FILE
+	Algorithm   string `yaml:"algorithm,omitempty"`    // Password encryption: aes-gcm or chacha20
+	KDF         string `yaml:"kdf,omitempty"`          // Argon2id profile: default, moderate or sensitive
+	Editor      string `yaml:"editor,omitempty"`       // Editor for podx config edit
+	UpdateCheck *bool  `yaml:"update_check,omitempty"` // Check for new versions in podx version
+	Format      string `yaml:"format,omitempty"`       // podx export output format
+}
+
+// IdentityProvider supplies identities from outside podx's own key storage,
+// e.g. a password manager or an identity file elsewhere
+type IdentityProvider struct {
+	Name     string `yaml:"name,omitempty"`
+	Command  string `yaml:"command,omitempty"`  // Run by the shell; its output is the identity
+	File     string `yaml:"file,omitempty"`     // Identity file; relative to the config file, ~ for home
+	Optional bool   `yaml:"optional,omitempty"` // Skip instead of failing when unavailable
+}
+
+// UserConfig is the content of config.yaml
+type UserConfig struct {
+	Settings   `yaml:",inline"`
+	Identities []IdentityProvider `yaml:"identities,omitempty"`
+}
+
+// Dir returns the podx config directory: $XDG_CONFIG_HOME/podx, or
+// ~/.config/podx. An existing ~/.config/podx is kept when the XDG one
+// doesn't exist yet, so setting XDG_CONFIG_HOME doesn't hide existing keys.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	legacy := filepath.Join(home, ".config", "podx")
+
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" || !filepath.IsAbs(xdg) {
+		return legacy, nil
+	}
+	dir := filepath.Join(xdg, "podx")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy, nil
+		}
+	}
+	return dir, nil
+}
+
+// Path returns the path of config.yaml
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// LoadUser reads config.yaml; a missing file is an empty config
+func LoadUser() (*UserConfig, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &UserConfig{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// FromEnv returns the settings given by environment variables
+func FromEnv() Settings {
+	s := Settings{
+		Identity:  os.Getenv("PODX_AGE_KEY_FILE"),
+		Algorithm: os.Getenv("PODX_ALGORITHM"),
+		KDF:       os.Getenv("PODX_KDF"),
+		Format:    os.Getenv("PODX_FORMAT"),
+	}
+	for _, env := range []string{"PODX_EDITOR", "VISUAL", "EDITOR"} {
+		if s.Editor = os.Getenv(env); s.Editor != "" {
+			break
+		}
+	}
+	if v := os.Getenv("PODX_UPDATE_CHECK"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			s.UpdateCheck = &enabled
+		}
+	}
+	return s
+}
+
+// Layer is one source of settings, named for podx config show
+type Layer struct {
+	Name string
+	Settings
+}
+
+// Resolved are the effective settings and where each one came from
+type Resolved struct {
+	Settings
+	Sources map[string]string // Setting name (as in config.yaml) to layer name
+}
+
+// Resolve merges layers, the first layer that sets a value wins, and fills
+// in the built-in defaults
+func Resolve(layers ...Layer) Resolved {
+	r := Resolved{Sources: map[string]string{}}
+
+	pick := func(name string, field func(*Settings) *string, def string) {
+		for _, l := range layers {
+			if v := *field(&l.Settings); v != "" {
+				*field(&r.Settings) = v
+				r.Sources[name] = l.Name
+				return
+			}
+		}
+		*field(&r.Settings) = def
+		r.Sources[name] = "default"
+	}
+	pick("identity", func(s *Settings) *string { return &s.Identity }, "")
+	pick("algorithm", func(s *Settings) *string { return &s.Algorithm }, "aes-gcm")
+	pick("kdf", func(s *Settings) *string { return &s.KDF }, "default")
+	pick("editor", func(s *Settings) *string { return &s.Editor }, defaultEditor())
+	pick("format", func(s *Settings) *string { return &s.Format }, "shell")
+
+	enabled := true
+	r.UpdateCheck = &enabled
+	r.Sources["update_check"] = "default"
+	for _, l := range layers {
+		if l.UpdateCheck != nil {
+			r.UpdateCheck = l.UpdateCheck
+			r.Sources["update_check"] = l.Name
+			break
+		}
+	}
+	return r
+}
+
+// KDFFloor keeps the KDF at least as strong as the user's: a project may ask
+// for a stronger KDF than the user's, never a weaker one. A KDF set by the
+// environment is left alone.
+func (r *Resolved) KDFFloor(user Layer) {
+	if r.Sources["kdf"] == "environment" || user.KDF == "" || user.KDF == r.KDF {
+		return
+	}
+	resolved, err1 := crypto.ParseKDFProfile(r.KDF)
+	floor, err2 := crypto.ParseKDFProfile(user.KDF)
+	if err1 == nil && err2 == nil && floor.ID > resolved.ID {
+		r.KDF = floor.Name
+		r.Sources["kdf"] = user.Name
+	}
+}
+
+func defaultEditor() string {
+	if os.PathSeparator == '\\' {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// ExpandPath expands a leading ~/ to the home directory and makes relative
+// paths relative to dir
+func ExpandPath(path, dir string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, rest), nil
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Join(dir, path), nil
+	}
+	return path, nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePrecedence(t *testing.T) {
+	for _, env := range []string{"PODX_AGE_KEY_FILE", "PODX_ALGORITHM", "PODX_KDF", "PODX_FORMAT", "PODX_EDITOR", "VISUAL", "EDITOR", "PODX_UPDATE_CHECK"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("PODX_ALGORITHM", "chacha20")
+	t.Setenv("PODX_UPDATE_CHECK", "false")
+
+	enabled := true
+	project := Layer{Name: "project", Settings: Settings{Algorithm: "aes-gcm", Format: "json", UpdateCheck: &enabled}}
+	user := Layer{Name: "user", Settings: Settings{Algorithm: "aes-gcm", Format: "yaml", Identity: "~/key.txt"}}
+
+	r := Resolve(Layer{Name: "environment", Settings: FromEnv()}, project, user)
+	tests := []struct {
+		name, got, want, source string
+	}{
+		{"algorithm", r.Algorithm, "chacha20", "environment"},
+		{"format", r.Format, "json", "project"},
+		{"identity", r.Identity, "~/key.txt", "user"},
+		{"kdf", r.KDF, "default", "default"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want || r.Sources[tt.name] != tt.source {
+			t.Errorf("%s = %q from %s, want %q from %s", tt.name, tt.got, r.Sources[tt.name], tt.want, tt.source)
+		}
+	}
+	if *r.UpdateCheck || r.Sources["update_check"] != "environment" {
+		t.Errorf("update_check = %v from %s", *r.UpdateCheck, r.Sources["update_check"])
+	}
+}
+
+func TestKDFFloor(t *testing.T) {
+	tests := []struct {
+		name         string
+		layers       []Layer
+		user         string
+		want, source string
+	}{
+		{"weaker project", []Layer{{Name: "project", Settings: Settings{KDF: "default"}}}, "moderate", "moderate", "user"},
+		{"stronger project", []Layer{{Name: "project", Settings: Settings{KDF: "sensitive"}}}, "moderate", "sensitive", "project"},
+		{"environment", []Layer{{Name: "environment", Settings: Settings{KDF: "default"}}}, "sensitive", "default", "environment"},
+		{"no user kdf", []Layer{{Name: "project", Settings: Settings{KDF: "default"}}}, "", "default", "project"},
+	}
+	for _, tt := range tests {
+		user := Layer{Name: "user", Settings: Settings{KDF: tt.user}}
+		r := Resolve(append(tt.layers, user)...)
+		r.KDFFloor(user)
+		if r.KDF != tt.want || r.Sources["kdf"] != tt.source {
+			t.Errorf("%s: kdf = %q from %s, want %q from %s", tt.name, r.KDF, r.Sources["kdf"], tt.want, tt.source)
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	legacy := filepath.Join(home, ".config", "podx")
+	xdg := filepath.Join(home, "xdg")
+
+	check := func(want string) {
+		t.Helper()
+		if dir, err := Dir(); err != nil || dir != want {
+			t.Errorf("Dir() = %q, %v, want %q", dir, err, want)
+		}
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	check(legacy)
+	// Relative XDG paths are invalid and ignored
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	check(legacy)
+
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	check(filepath.Join(xdg, "podx"))
+
+	// Existing keys in ~/.config/podx aren't hidden by a new XDG_CONFIG_HOME
+	if err := os.MkdirAll(legacy, 0700); err != nil {
+		t.Fatal(err)
+	}
+	check(legacy)
+
+	// Once the XDG directory exists it wins
+	if err := os.MkdirAll(filepath.Join(xdg, "podx"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	check(filepath.Join(xdg, "podx"))
+}
@@ -2,18 +2,22 @@ package crypto
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 
 	"filippo.io/age"
+	"filippo.io/age/armor"
 )
 
-// AgeEncrypt mengenkripsi plaintext dengan Age encryption menggunakan public key recipient
+// AgeEncrypt mengenkripsi plaintext dengan Age encryption menggunakan public key
+// recipient (age1... atau SSH public key)
 func AgeEncrypt(plaintext []byte, recipients ...string) ([]byte, error) {
 	var ageRecipients []age.Recipient
 	for _, r := range recipients {
-		recipient, err := age.ParseX25519Recipient(r)
+		recipient, err := ParseRecipient(r)
 		if err != nil {
 			return nil, fmt.Errorf("invalid recipient '%s': %w", r, err)
 		}
@@ -37,15 +41,37 @@ func AgeEncrypt(plaintext []byte, recipients ...string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// AgeDecrypt mendekripsi ciphertext dengan Age private key identity
+// AgeDecrypt mendekripsi ciphertext dengan identity Age dan/atau SSH private key
+// (lihat ParseIdentities)
 func AgeDecrypt(ciphertext []byte, identityData string) ([]byte, error) {
-	identity, err := age.ParseX25519Identity(strings.TrimSpace(identityData))
+	identities, err := ParseIdentities(identityData)
 	if err != nil {
 		return nil, fmt.Errorf("invalid identity: %w", err)
 	}
 
-	r, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	// Terima juga format armored (-----BEGIN AGE ENCRYPTED FILE-----)
+	var src io.Reader = bytes.NewReader(ciphertext)
+	if bytes.HasPrefix(bytes.TrimSpace(ciphertext), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(ciphertext)))
+	}
+
+	r, err := age.Decrypt(src, identities...)
 	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			// Sumber identity yang gagal dimuat mungkin berisi key yang dicari
+			var unavailable []string
+			for _, e := range noMatch.Errors {
+				var u *unavailableError
+				if errors.As(e, &u) && !slices.Contains(unavailable, u.msg) {
+					unavailable = append(unavailable, u.msg)
+				}
+			}
+			if len(unavailable) > 0 {
+				return nil, fmt.Errorf("failed to decrypt: %w (encrypted to %s; not loaded: %s)", err, describeStanzas(noMatch.StanzaTypes), strings.Join(unavailable, "; "))
+			}
+			return nil, fmt.Errorf("failed to decrypt: %w (encrypted to %s)", err, describeStanzas(noMatch.StanzaTypes))
+		}
 		return nil, fmt.Errorf("failed to decrypt: %w", err)
 	}
 
@@ -57,6 +83,37 @@ func AgeDecrypt(ciphertext []byte, identityData string) ([]byte, error) {
 	return plaintext, nil
 }
 
+// IsNoIdentityMatch mengecek apakah dekripsi gagal karena tidak ada identity
+// yang cocok dengan recipient file
+func IsNoIdentityMatch(err error) bool {
+	var noMatch *age.NoIdentityMatchError
+	return errors.As(err, &noMatch)
+}
+
+// describeStanzas meringkas tipe recipient di header file, misalnya
+// "2 X25519 recipients, 1 ssh-ed25519 recipient". Recipient X25519 dan
+// post-quantum anonim, jadi hanya tipenya yang bisa diketahui.
+func describeStanzas(types []string) string {
+	var order []string
+	count := map[string]int{}
+	for _, t := range types {
+		if count[t] == 0 {
+			order = append(order, t)
+		}
+		count[t]++
+	}
+
+	var parts []string
+	for _, t := range order {
+		noun := "recipient"
+		if count[t] > 1 {
+			noun = "recipients"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s %s", count[t], t, noun))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // GenerateAgeKey generates a new Age X25519 key pair
 // Returns: privateKey, publicKey, error
 func GenerateAgeKey() (string, string, error) {
@@ -67,3 +124,44 @@ func GenerateAgeKey() (string, string, error) {
 
 	return identity.String(), identity.Recipient().String(), nil
 }
+
+// AgePublicKey mengembalikan public key dari private key Age (X25519 atau hybrid)
+func AgePublicKey(privateKey string) (string, error) {
+	privateKey = strings.TrimSpace(privateKey)
+	if strings.HasPrefix(privateKey, "AGE-SECRET-KEY-PQ-") {
+		identity, err := age.ParseHybridIdentity(privateKey)
+		if err != nil {
+			return "", err
+		}
+		return identity.Recipient().String(), nil
+	}
+
+	identity, err := age.ParseX25519Identity(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return identity.Recipient().String(), nil
+}
+
+// GenerateAgePQKey generates a post-quantum hybrid (ML-KEM-768 + X25519) key
+// pair, compatible with age's age1pq1... recipients
+// Returns: privateKey, publicKey, error
+func GenerateAgePQKey() (string, string, error) {
+	identity, err := age.GenerateHybridIdentity()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate age post-quantum key: %w", err)
+	}
+
+	return identity.String(), identity.Recipient().String(), nil
+}
+
+// IsPQRecipient mengecek apakah recipient adalah hybrid post-quantum (age1pq1...)
+func IsPQRecipient(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "age1pq1")
+}
+
+// IsAgeCiphertext mengecek apakah data adalah file Age (binary atau armored)
+func IsAgeCiphertext(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("age-encryption.org/")) ||
+		bytes.HasPrefix(data, []byte(armor.Header))
+}
@@ -0,0 +1,112 @@
+package crypto
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+)
+
+// TestMain menjalankan binary test sebagai age-plugin-podxtest kalau
+// dipanggil dengan nama itu (lihat installTestPlugin)
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) != "age-plugin-podxtest" {
+		os.Exit(m.Run())
+	}
+
+	p, _ := plugin.New("podxtest")
+	p.HandleRecipient(func(data []byte) (age.Recipient, error) {
+		return testPluginKey{}, nil
+	})
+	p.HandleIdentity(func(data []byte) (age.Identity, error) {
+		return testPluginKey{}, nil
+	})
+	os.Exit(p.Main())
+}
+
+// testPluginKey menyimpan file key apa adanya di stanza "podxtest"
+type testPluginKey struct{}
+
+func (testPluginKey) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	return []*age.Stanza{{Type: "podxtest", Body: fileKey}}, nil
+}
+
+func (testPluginKey) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type == "podxtest" {
+			return s.Body, nil
+		}
+	}
+	return nil, age.ErrIncorrectIdentity
+}
+
+// installTestPlugin menaruh age-plugin-podxtest (binary test ini) di PATH
+func installTestPlugin(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin stub needs symlinks")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(exe, filepath.Join(dir, "age-plugin-podxtest")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestAgePluginRoundTrip(t *testing.T) {
+	installTestPlugin(t)
+
+	recipient := plugin.EncodeRecipient("podxtest", []byte{1})
+	identity := plugin.EncodeIdentity("podxtest", []byte{1})
+
+	ciphertext, err := AgeEncrypt([]byte("hunter2"), recipient)
+	if err != nil {
+		t.Fatalf("AgeEncrypt: %v", err)
+	}
+	plaintext, err := AgeDecrypt(ciphertext, "# plugin key\n"+identity+"\n")
+	if err != nil {
+		t.Fatalf("AgeDecrypt: %v", err)
+	}
+	if string(plaintext) != "hunter2" {
+		t.Errorf("got %q, want %q", plaintext, "hunter2")
+	}
+}
+
+func TestAgePluginWrongIdentity(t *testing.T) {
+	installTestPlugin(t)
+
+	ciphertext, err := AgeEncrypt([]byte("hunter2"), plugin.EncodeRecipient("podxtest", []byte{1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = AgeDecrypt(ciphertext, other)
+	if !IsNoIdentityMatch(err) {
+		t.Fatalf("expected no identity match, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "podxtest") {
+		t.Errorf("error does not name the plugin stanza: %v", err)
+	}
+}
+
+func TestAgePluginMissing(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := AgeEncrypt([]byte("x"), plugin.EncodeRecipient("podxtest", []byte{1}))
+	if err == nil {
+		t.Fatal("expected an error for a missing plugin")
+	}
+}
@@ -0,0 +1,394 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"filippo.io/age/plugin"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHPassphrase dipanggil saat SSH private key terenkripsi dibutuhkan untuk
+// dekripsi. name adalah path atau fingerprint key. Di-set oleh CLI.
+var SSHPassphrase func(name string) ([]byte, error)
+
+// IdentityPassphrase dipanggil saat identity file yang dilindungi passphrase
+// (lihat ProtectIdentity) dibutuhkan untuk dekripsi. Di-set oleh CLI.
+var IdentityPassphrase func(name string) ([]byte, error)
+
+// AgentIdentity membuat identity untuk baris "PODX-AGENT <socket>", yang
+// meneruskan unwrap ke podx agent. Di-set oleh CLI (lihat paket agent).
+var AgentIdentity func(socket string) age.Identity
+
+// pluginUI menampilkan pesan dan prompt dari age-plugin-* lewat terminal
+// (/dev/tty), jadi stdout tetap bersih untuk pipeline
+var pluginUI = plugin.NewTerminalUI(
+	func(format string, v ...any) { fmt.Fprintf(os.Stderr, format+"\n", v...) },
+	func(format string, v ...any) { fmt.Fprintf(os.Stderr, "⚠️  "+format+"\n", v...) },
+)
+
+// identityCache menyimpan hasil parse identity supaya passphrase SSH key
+// hanya ditanyakan sekali per proses
+var (
+	identityCache   = map[string][]age.Identity{}
+	identityCacheMu sync.Mutex
+)
+
+// ParseRecipient mem-parse public key Age (age1..., age1pq1..., atau
+// age1<plugin>1... untuk plugin) atau SSH (ssh-ed25519, ssh-rsa)
+func ParseRecipient(s string) (age.Recipient, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "age1pq1"):
+		return age.ParseHybridRecipient(s)
+	case strings.HasPrefix(s, "age1"):
+		if r, err := age.ParseX25519Recipient(s); err == nil {
+			return r, nil
+		}
+		// age1<plugin>1... ditangani oleh age-plugin-<plugin> di PATH
+		if _, _, err := plugin.ParseRecipient(s); err == nil {
+			return plugin.NewRecipient(s, pluginUI)
+		}
+		return age.ParseX25519Recipient(s)
+	case strings.HasPrefix(s, "ssh-"):
+		return agessh.ParseRecipient(s)
+	default:
+		return nil, fmt.Errorf("unknown recipient type (expected age1..., age1pq1..., age1<plugin>1..., ssh-ed25519 or ssh-rsa)")
+	}
+}
+
+// ParseIdentities mem-parse identity data: baris AGE-SECRET-KEY-... (termasuk
+// hybrid AGE-SECRET-KEY-PQ-...), identity plugin AGE-PLUGIN-... (dijalankan
+// lewat age-plugin-* di PATH), blok PEM SSH private key dan/atau identity file
+// terenkripsi passphrase (blok armored age, lihat ProtectIdentity). Komentar
+// "# <nama>" sebelum blok PEM/armored dipakai sebagai nama key di prompt passphrase,
+// dan "# public key: ssh-..." sebagai public key untuk SSH key terenkripsi
+// yang tidak menyimpannya sendiri.
+func ParseIdentities(data string) ([]age.Identity, error) {
+	identityCacheMu.Lock()
+	defer identityCacheMu.Unlock()
+
+	if ids, ok := identityCache[data]; ok {
+		return ids, nil
+	}
+
+	ids, err := LoadIdentities(data)
+	if err != nil {
+		return nil, err
+	}
+	identityCache[data] = ids
+	return ids, nil
+}
+
+// LoadIdentities sama dengan ParseIdentities tanpa cache, untuk podx agent
+// yang harus bisa melepas key dari memori saat di-lock. Identity file yang
+// dilindungi passphrase selalu diletakkan paling akhir, jadi passphrase hanya
+// ditanyakan kalau tidak ada identity lain yang cocok.
+func LoadIdentities(data string) ([]age.Identity, error) {
+	var ids, protected []age.Identity
+	var name, pubKey string
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case strings.HasPrefix(line, "# public key: ssh-"):
+			pubKey = strings.TrimPrefix(line, "# public key: ")
+		case strings.HasPrefix(line, "#"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		case strings.HasPrefix(line, "AGE-PLUGIN-"):
+			id, err := plugin.NewIdentity(line, pluginUI)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		case strings.HasPrefix(line, "PODX-UNAVAILABLE "):
+			ids = append(ids, unavailableIdentity(strings.TrimPrefix(line, "PODX-UNAVAILABLE ")))
+		case strings.HasPrefix(line, "PODX-AGENT "):
+			if AgentIdentity == nil {
+				continue
+			}
+			ids = append(ids, AgentIdentity(strings.TrimSpace(strings.TrimPrefix(line, "PODX-AGENT "))))
+		case strings.HasPrefix(line, "AGE-SECRET-KEY-PQ-"):
+			id, err := age.ParseHybridIdentity(line)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		case strings.HasPrefix(line, "AGE-SECRET-KEY-"):
+			id, err := age.ParseX25519Identity(line)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		case line == armor.Header:
+			start := i
+			for i < len(lines) && strings.TrimSpace(lines[i]) != armor.Footer {
+				i++
+			}
+			if i == len(lines) {
+				return nil, fmt.Errorf("unterminated encrypted identity")
+			}
+			if name == "" {
+				name = "identity file"
+			}
+			protected = append(protected, &protectedIdentity{name: name, data: strings.Join(lines[start:i+1], "\n") + "\n"})
+			name = ""
+		case strings.HasPrefix(line, "-----BEGIN ") && strings.HasSuffix(line, "PRIVATE KEY-----"):
+			start := i
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "-----END ") {
+				i++
+			}
+			if i == len(lines) {
+				return nil, fmt.Errorf("unterminated PEM block in identity")
+			}
+			pem := []byte(strings.Join(lines[start:i+1], "\n") + "\n")
+
+			id, err := parseSSHIdentity(pem, name, pubKey)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+			name, pubKey = "", ""
+		}
+	}
+
+	ids = append(ids, protected...)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no identity found")
+	}
+	return ids, nil
+}
+
+func parseSSHIdentity(pem []byte, name, pubKey string) (age.Identity, error) {
+	id, err := agessh.ParseIdentity(pem)
+	if err == nil {
+		return id, nil
+	}
+
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return nil, fmt.Errorf("invalid SSH key %s: %w", name, err)
+	}
+
+	pk := missing.PublicKey
+	if pk == nil && pubKey != "" {
+		if pk, _, _, _, err = ssh.ParseAuthorizedKey([]byte(pubKey)); err != nil {
+			return nil, fmt.Errorf("invalid SSH public key for %s: %w", name, err)
+		}
+	}
+	if pk == nil {
+		return nil, fmt.Errorf("SSH key %s is encrypted and its public key is unknown (add the .pub file)", name)
+	}
+	if name == "" {
+		name = ssh.FingerprintSHA256(pk)
+	}
+
+	return agessh.NewEncryptedSSHIdentity(pk, pem, func() ([]byte, error) {
+		if SSHPassphrase == nil {
+			return nil, fmt.Errorf("SSH key %s is encrypted and no passphrase prompt is available", name)
+		}
+		return SSHPassphrase(name)
+	})
+}
+
+// UnavailableLine membuat baris identity "PODX-UNAVAILABLE <pesan>" untuk
+// sumber identity yang gagal dimuat (misalnya command password manager).
+// Baris ini tidak pernah cocok; pesannya hanya muncul di error AgeDecrypt
+// kalau tidak ada identity lain yang bisa mendekripsi.
+func UnavailableLine(err error) string {
+	return "PODX-UNAVAILABLE " + strings.Join(strings.Fields(err.Error()), " ")
+}
+
+// unavailableIdentity adalah sumber identity yang gagal dimuat
+type unavailableIdentity string
+
+func (u unavailableIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	return nil, &unavailableError{string(u)}
+}
+
+// unavailableError membungkus age.ErrIncorrectIdentity supaya age mencoba
+// identity berikutnya
+type unavailableError struct {
+	msg string
+}
+
+func (e *unavailableError) Error() string { return e.msg }
+func (e *unavailableError) Unwrap() error { return age.ErrIncorrectIdentity }
+
+// protectedIdentity adalah identity file yang dienkripsi dengan passphrase.
+// Passphrase baru ditanyakan saat identity ini benar-benar dicoba, dan hasil
+// dekripsinya disimpan supaya hanya ditanyakan sekali per proses.
+type protectedIdentity struct {
+	name string
+	data string
+	ids  []age.Identity
+}
+
+func (p *protectedIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	if p.ids == nil {
+		if IdentityPassphrase == nil {
+			return nil, fmt.Errorf("%s is passphrase-protected and no passphrase prompt is available", p.name)
+		}
+		pass, err := IdentityPassphrase(p.name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := UnprotectIdentity([]byte(p.data), string(pass))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+		ids, err := ParseIdentities(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+		p.ids = ids
+	}
+
+	for _, id := range p.ids {
+		fileKey, err := id.Unwrap(stanzas)
+		if errors.Is(err, age.ErrIncorrectIdentity) {
+			continue
+		}
+		return fileKey, err
+	}
+	return nil, age.ErrIncorrectIdentity
+}
+
+// ProtectIdentity mengenkripsi identity file dengan passphrase (age scrypt,
+// armored), kompatibel dengan 'age -p -a' dan 'age -d -i'
+func ProtectIdentity(data []byte, passphrase string) ([]byte, error) {
+	r, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create age encryptor: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write identity: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close encryptor: %w", err)
+	}
+	if err := aw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close armor: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnprotectIdentity mendekripsi identity file hasil ProtectIdentity
+func UnprotectIdentity(data []byte, passphrase string) ([]byte, error) {
+	id, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(bytes.TrimSpace(data))), id)
+	if err != nil {
+		if IsNoIdentityMatch(err) {
+			return nil, fmt.Errorf("incorrect passphrase")
+		}
+		return nil, fmt.Errorf("failed to decrypt identity: %w", err)
+	}
+	return io.ReadAll(r)
+}
+
+// UnlockIdentities membuka semua identity yang dilindungi passphrase (identity
+// file terenkripsi dan SSH key terenkripsi) lewat IdentityPassphrase dan
+// SSHPassphrase, supaya hasilnya bisa dipakai tanpa prompt oleh podx agent.
+// Identity plugin dan baris PODX-AGENT dibuang karena butuh terminal atau
+// mengarah ke agent itu sendiri.
+func UnlockIdentities(data string) (string, error) {
+	var sb strings.Builder
+	var name string
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case strings.HasPrefix(line, "# public key: ssh-"):
+			// Tidak dibutuhkan lagi setelah key dibuka
+		case strings.HasPrefix(line, "#"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			sb.WriteString(line + "\n")
+		case strings.HasPrefix(line, "AGE-SECRET-KEY-"):
+			sb.WriteString(line + "\n")
+		case line == armor.Header || (strings.HasPrefix(line, "-----BEGIN ") && strings.HasSuffix(line, "PRIVATE KEY-----")):
+			start := i
+			for i < len(lines) && strings.TrimSpace(lines[i]) != armor.Footer && !strings.HasPrefix(strings.TrimSpace(lines[i]), "-----END ") {
+				i++
+			}
+			if i == len(lines) {
+				return "", fmt.Errorf("unterminated block in identity")
+			}
+			block := []byte(strings.Join(lines[start:i+1], "\n") + "\n")
+
+			var unlocked string
+			var err error
+			if line == armor.Header {
+				unlocked, err = unlockIdentityFile(block, name)
+			} else {
+				unlocked, err = unlockSSHKey(block, name)
+			}
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(strings.TrimSpace(unlocked) + "\n")
+			name = ""
+		}
+	}
+	return sb.String(), nil
+}
+
+func unlockIdentityFile(block []byte, name string) (string, error) {
+	if IdentityPassphrase == nil {
+		return "", fmt.Errorf("%s is passphrase-protected and no passphrase prompt is available", name)
+	}
+	pass, err := IdentityPassphrase(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := UnprotectIdentity(block, string(pass))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return UnlockIdentities(string(data))
+}
+
+func unlockSSHKey(data []byte, name string) (string, error) {
+	var missing *ssh.PassphraseMissingError
+	if _, err := ssh.ParseRawPrivateKey(data); err == nil {
+		return string(data), nil
+	} else if !errors.As(err, &missing) {
+		return "", fmt.Errorf("invalid SSH key %s: %w", name, err)
+	}
+
+	if SSHPassphrase == nil {
+		return "", fmt.Errorf("SSH key %s is encrypted and no passphrase prompt is available", name)
+	}
+	pass, err := SSHPassphrase(name)
+	if err != nil {
+		return "", err
+	}
+	key, err := ssh.ParseRawPrivateKeyWithPassphrase(data, pass)
+	if err != nil {
+		return "", fmt.Errorf("SSH key %s: %w", name, err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return "", fmt.Errorf("SSH key %s: %w", name, err)
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
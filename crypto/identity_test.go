@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProtectedIdentityUnlockedLast(t *testing.T) {
+	protectedKey, protectedRecipient, err := GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainKey, plainRecipient, err := GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	armored, err := ProtectIdentity([]byte(protectedKey+"\n"), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The protected identity comes first in the file
+	identities := "# work key\n" + string(armored) + "# other key\n" + plainKey + "\n"
+
+	prompts := 0
+	saved := IdentityPassphrase
+	t.Cleanup(func() { IdentityPassphrase = saved })
+	IdentityPassphrase = func(name string) ([]byte, error) {
+		prompts++
+		if name != "work key" {
+			t.Errorf("prompted for %q", name)
+		}
+		return []byte("correct horse"), nil
+	}
+
+	ciphertext, err := AgeEncrypt([]byte("plain"), plainRecipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AgeDecrypt(ciphertext, identities); err != nil {
+		t.Fatal(err)
+	}
+	if prompts != 0 {
+		t.Fatalf("prompted %d times although an unprotected key matched", prompts)
+	}
+
+	ciphertext, err = AgeEncrypt([]byte("protected"), protectedRecipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := AgeDecrypt(ciphertext, identities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "protected" || prompts != 1 {
+		t.Errorf("got %q after %d prompts", plaintext, prompts)
+	}
+}
+
+func TestProtectedIdentityWrongPassphrase(t *testing.T) {
+	key, recipient, err := GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	armored, err := ProtectIdentity([]byte(key+"\n"), "right")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := IdentityPassphrase
+	t.Cleanup(func() { IdentityPassphrase = saved })
+	IdentityPassphrase = func(string) ([]byte, error) { return []byte("wrong"), nil }
+
+	ciphertext, err := AgeEncrypt([]byte("x"), recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = AgeDecrypt(ciphertext, "# wrong passphrase test\n"+string(armored))
+	if err == nil || !strings.Contains(err.Error(), "incorrect passphrase") {
+		t.Fatalf("expected an incorrect passphrase error, got %v", err)
+	}
+}
@@ -19,10 +19,46 @@ const (
 	SaltSize = 16
 )
 
-// DeriveKey menghasilkan 256-bit key dari password menggunakan Argon2id.
-// Returns: key (32 bytes), salt (16 bytes), error
-func DeriveKey(password []byte, salt []byte) ([]byte, []byte, error) {
-	// Generate salt jika tidak diberikan
+// KDFProfile adalah set parameter Argon2id. ID disimpan di file terenkripsi
+// supaya dekripsi memakai parameter yang sama.
+type KDFProfile struct {
+	ID     byte
+	Name   string
+	Time   uint32
+	Memory uint32 // KiB
+}
+
+// KDFProfiles berisi profile yang didukung; "default" dipakai oleh file lama
+// yang tidak menyimpan profile
+var KDFProfiles = []KDFProfile{
+	{ID: 0, Name: "default", Time: argon2Time, Memory: argon2Memory},
+	{ID: 1, Name: "moderate", Time: 3, Memory: 256 * 1024},
+	{ID: 2, Name: "sensitive", Time: 4, Memory: 1024 * 1024},
+}
+
+// ParseKDFProfile mencari profile berdasarkan nama
+func ParseKDFProfile(name string) (KDFProfile, error) {
+	for _, p := range KDFProfiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return KDFProfile{}, fmt.Errorf("unknown KDF profile: %s (supported: default, moderate, sensitive)", name)
+}
+
+// KDFProfileByID mencari profile berdasarkan ID yang tersimpan di file
+func KDFProfileByID(id byte) (KDFProfile, error) {
+	for _, p := range KDFProfiles {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return KDFProfile{}, fmt.Errorf("unknown KDF profile id %d (file made by a newer podx?)", id)
+}
+
+// DeriveKey menghasilkan 256-bit key dari password dengan parameter profile
+// ini. Salt baru dibuat jika salt nil.
+func (p KDFProfile) DeriveKey(password, salt []byte) ([]byte, []byte, error) {
 	if salt == nil {
 		salt = make([]byte, SaltSize)
 		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
@@ -34,18 +70,23 @@ func DeriveKey(password []byte, salt []byte) ([]byte, []byte, error) {
 		return nil, nil, fmt.Errorf("invalid salt size: expected %d bytes, got %d", SaltSize, len(salt))
 	}
 
-	// Derive key menggunakan Argon2id
-	key := argon2.IDKey(password, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
-
+	key := argon2.IDKey(password, salt, p.Time, p.Memory, argon2Threads, argon2KeyLen)
 	return key, salt, nil
 }
 
+// DeriveKey menghasilkan 256-bit key dari password menggunakan Argon2id
+// (profile default).
+// Returns: key (32 bytes), salt (16 bytes), error
+func DeriveKey(password []byte, salt []byte) ([]byte, []byte, error) {
+	return KDFProfiles[0].DeriveKey(password, salt)
+}
+
 // DeriveKeyWithSalt menghasilkan key dari password dengan salt yang sudah ada.
 func DeriveKeyWithSalt(password, salt []byte) ([]byte, error) {
 	if len(salt) != SaltSize {
 		return nil, fmt.Errorf("invalid salt size: expected %d bytes, got %d", SaltSize, len(salt))
 	}
 
-	key := argon2.IDKey(password, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
-	return key, nil
+	key, _, err := KDFProfiles[0].DeriveKey(password, salt)
+	return key, err
 }
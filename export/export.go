@@ -0,0 +1,173 @@
+package export
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hades/podx/parser"
+)
+
+// Formats lists the supported output formats of podx export
+var Formats = []string{"shell", "json", "yaml", "docker-env", "systemd", "github-output", "k8s-secret", "tfvars"}
+
+// validKey matches variable names every line-based format can carry. Other
+// keys could inject code, e.g. into eval "$(podx export)".
+var validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Options holds format-specific settings
+type Options struct {
+	Name      string // k8s-secret: metadata.name
+	Namespace string // k8s-secret: metadata.namespace
+}
+
+// Render formats decrypted variables for the given target
+func Render(format string, vars []parser.EnvVar, opts Options) ([]byte, error) {
+	switch format {
+	case "shell":
+		return renderLines(vars, func(v parser.EnvVar) (string, error) {
+			return fmt.Sprintf("export %s=%s", v.Key, ShellQuote(v.Value)), nil
+		})
+	case "json":
+		return renderJSON(vars)
+	case "yaml":
+		return renderYAML(vars)
+	case "docker-env":
+		// docker --env-file takes values literally and has no multi-line syntax
+		return renderLines(vars, func(v parser.EnvVar) (string, error) {
+			if strings.ContainsAny(v.Value, "\r\n") {
+				return "", fmt.Errorf("%s: docker env files cannot contain multi-line values", v.Key)
+			}
+			return v.Key + "=" + v.Value, nil
+		})
+	case "systemd":
+		return renderLines(vars, func(v parser.EnvVar) (string, error) {
+			return fmt.Sprintf("%s=%s", v.Key, systemdQuote(v.Value)), nil
+		})
+	case "github-output":
+		return renderLines(vars, githubOutputLine)
+	case "k8s-secret":
+		return K8sSecret(vars, opts.Name, opts.Namespace)
+	case "tfvars":
+		return renderLines(vars, func(v parser.EnvVar) (string, error) {
+			return fmt.Sprintf("%s = %s", v.Key, hclQuote(v.Value)), nil
+		})
+	default:
+		return nil, fmt.Errorf("unknown format: %s (supported: %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+func renderLines(vars []parser.EnvVar, line func(v parser.EnvVar) (string, error)) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, v := range vars {
+		if !validKey.MatchString(v.Key) {
+			return nil, fmt.Errorf("invalid variable name %q: use letters, digits and _ only, not starting with a digit", v.Key)
+		}
+		s, err := line(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(s + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// ShellQuote quotes s for POSIX shells using single quotes
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// systemdQuote quotes s for systemd EnvironmentFile= (double quotes, with
+// the characters systemd unescapes prefixed by a backslash)
+func systemdQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		if strings.ContainsRune("\"\\`$", r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// hclQuote quotes s as a Terraform string literal, escaping template sequences
+func hclQuote(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+		"${", "$${",
+		"%{", "%%{",
+	)
+	return `"` + r.Replace(s) + `"`
+}
+
+// githubOutputLine writes KEY=value, or the KEY<<DELIMITER heredoc syntax
+// for multi-line values (as used by $GITHUB_OUTPUT and $GITHUB_ENV)
+func githubOutputLine(v parser.EnvVar) (string, error) {
+	if !strings.ContainsAny(v.Value, "\r\n") {
+		return v.Key + "=" + v.Value, nil
+	}
+
+	// Random delimiter so a value can never terminate the heredoc early
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(b)
+
+	return fmt.Sprintf("%s<<%s\n%s\n%s", v.Key, delimiter, v.Value, delimiter), nil
+}
+
+func renderJSON(vars []parser.EnvVar) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, v := range vars {
+		key, err := json.Marshal(v.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, "\n  %s: %s", key, value)
+	}
+	if len(vars) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+func renderYAML(vars []parser.EnvVar) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, v := range vars {
+		// Tag !!str keeps values like "true" or "0123" quoted
+		addField(root, v.Key, v.Value)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to render YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to render YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hades/podx/parser"
+)
+
+func TestRenderRejectsInvalidKeys(t *testing.T) {
+	keys := []string{"weird key;id", "1ABC", "A-B", "$(id)", ""}
+	for _, format := range []string{"shell", "docker-env", "systemd", "github-output", "tfvars"} {
+		for _, key := range keys {
+			vars := []parser.EnvVar{{Key: "OK", Value: "1"}, {Key: key, Value: "1"}}
+			if out, err := Render(format, vars, Options{}); err == nil {
+				t.Errorf("%s: key %q accepted:\n%s", format, key, out)
+			}
+		}
+	}
+}
+
+func TestRenderShell(t *testing.T) {
+	vars := []parser.EnvVar{
+		{Key: "A", Value: "plain"},
+		{Key: "_B2", Value: "it's $HOME"},
+	}
+	out, err := Render("shell", vars, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "export A='plain'\nexport _B2='it'\\''s $HOME'\n"
+	if string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderJSONKeepsAnyKey(t *testing.T) {
+	out, err := Render("json", []parser.EnvVar{{Key: "a b", Value: "1"}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"a b": "1"`) {
+		t.Errorf("unexpected output %s", out)
+	}
+}
@@ -0,0 +1,70 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hades/podx/parser"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// SecretName derives a valid Kubernetes object name from a file path,
+// e.g. ".env.prod.podx" → "env-prod"
+func SecretName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".podx")
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(strings.ReplaceAll(name, ".", "-"), "-")
+	if name == "" {
+		return "secrets"
+	}
+	return name
+}
+
+// K8sSecret renders variables as a Kubernetes Secret manifest with
+// base64-encoded data, keeping the order of the source file
+func K8sSecret(vars []parser.EnvVar, name, namespace string) ([]byte, error) {
+	metadata := &yaml.Node{Kind: yaml.MappingNode}
+	addField(metadata, "name", name)
+	if namespace != "" {
+		addField(metadata, "namespace", namespace)
+	}
+
+	data := &yaml.Node{Kind: yaml.MappingNode}
+	for _, v := range vars {
+		addField(data, v.Key, base64.StdEncoding.EncodeToString([]byte(v.Value)))
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	addField(root, "apiVersion", "v1")
+	addField(root, "kind", "Secret")
+	root.Content = append(root.Content, scalar("metadata"), metadata)
+	addField(root, "type", "Opaque")
+	root.Content = append(root.Content, scalar("data"), data)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to render secret: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to render secret: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func addField(mapping *yaml.Node, key, value string) {
+	mapping.Content = append(mapping.Content, scalar(key), scalar(value))
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hades/podx/parser"
+)
+
+func TestSecretName(t *testing.T) {
+	tests := map[string]string{
+		".env.podx":              "env",
+		"config/.env.prod.podx":  "env-prod",
+		"App_Secrets.yaml":       "app-secrets-yaml",
+		"___.podx":               "secrets",
+		"deploy/db password.env": "db-password-env",
+	}
+	for path, want := range tests {
+		if got := SecretName(path); got != want {
+			t.Errorf("SecretName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestK8sSecret(t *testing.T) {
+	vars := []parser.EnvVar{
+		{Key: "Z_LAST", Value: "hunter2"},
+		{Key: "A_FIRST", Value: "line\nbreak"},
+	}
+	out, err := K8sSecret(vars, "api", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `apiVersion: v1
+kind: Secret
+metadata:
+  name: api
+  namespace: prod
+type: Opaque
+data:
+  Z_LAST: aHVudGVyMg==
+  A_FIRST: bGluZQpicmVhaw==
+`
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+
+	// The manifest is itself recognized by the YAML codec
+	var doc yaml.Node
+	if err := yaml.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !parser.IsK8sSecret(&doc) {
+		t.Error("rendered manifest not recognized as a Secret")
+	}
+
+	out, err = Render("k8s-secret", vars[:1], Options{Name: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "apiVersion: v1\nkind: Secret\nmetadata:\n  name: api\ntype: Opaque\ndata:\n  Z_LAST: aHVudGVyMg==\n" {
+		t.Errorf("namespace not omitted:\n%s", out)
+	}
+}
@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"text/template"
+)
+
+// SecretLookup returns the decrypted value of key in an encrypted file
+type SecretLookup func(file, key string) (string, error)
+
+// Template evaluates a text/template with helpers for reading secrets:
+//
+//	{{ secret ".env.podx" "DB_PASSWORD" }}
+//	{{ secret ".env.podx" "TLS_CERT" | b64enc }}
+//	{{ secret ".env.podx" "DB_PASSWORD" | shellQuote }}
+//
+// Missing keys are errors rather than empty strings. Templates are executed
+// with an empty map as data, so missingkey=error also rejects a stray
+// {{ .Key }}.
+func Template(name string, text []byte, lookup SecretLookup) ([]byte, error) {
+	funcs := template.FuncMap{
+		"secret":     lookup,
+		"b64enc":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":     b64dec,
+		"toJson":     toJSON,
+		"shellQuote": ShellQuote,
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]any{}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func b64dec(s string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("b64dec: %w", err)
+	}
+	return string(decoded), nil
+}
+
+// toJSON encodes v as JSON without HTML escaping, so it can be embedded in
+// config files as a quoted string, number or object
+func toJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
@@ -0,0 +1,62 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testLookup(file, key string) (string, error) {
+	values := map[string]string{"API_TOKEN": "t0k<en>", "PASS": "it's", "B64": "aGk=", "PORT": "5432"}
+	if v, ok := values[key]; ok && file == ".env.podx" {
+		return v, nil
+	}
+	return "", fmt.Errorf("%s: key '%s' not found", file, key)
+}
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`Bearer {{ secret ".env.podx" "API_TOKEN" }}`, "Bearer t0k<en>"},
+		{`pass={{ secret ".env.podx" "PASS" | shellQuote }}`, `pass='it'\''s'`},
+		{`{{ secret ".env.podx" "API_TOKEN" | b64enc }}`, "dDBrPGVuPg=="},
+		{`{{ secret ".env.podx" "B64" | b64dec }}`, "hi"},
+		{`{"token": {{ secret ".env.podx" "API_TOKEN" | toJson }}}`, `{"token": "t0k<en>"}`},
+	}
+	for _, tt := range tests {
+		out, err := Template("t", []byte(tt.text), testLookup)
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		if string(out) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.text, out, tt.want)
+		}
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{{ secret ".env.podx" "MISSING" }}`, "key 'MISSING' not found"},
+		{`{{ secret "other.podx" "PASS" }}`, "other.podx"},
+		{`{{ .DB_PASSWORD }}`, "DB_PASSWORD"},
+		{`{{ secret ".env.podx" "PASS" | b64dec }}`, "b64dec"},
+		{`{{ secret ".env.podx" }}`, "secret"},
+		{`{{ unknown }}`, "unknown"},
+	}
+	for _, tt := range tests {
+		out, err := Template("t", []byte(tt.text), testLookup)
+		if err == nil {
+			t.Errorf("%s: rendered %q, want an error", tt.text, out)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %q does not mention %q", tt.text, err, tt.want)
+		}
+	}
+}
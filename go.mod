@@ -3,10 +3,14 @@ module github.com/hades/podx
 go 1.25.5
 
 require (
-	filippo.io/age v1.2.1
+	filippo.io/age v1.3.1
 	golang.org/x/crypto v0.46.0
 	golang.org/x/term v0.38.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.39.0 // indirect
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	filippo.io/hpke v0.4.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+)
@@ -0,0 +1,527 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hades/podx/crypto"
+	"github.com/hades/podx/parser"
+)
+
+// sopsValuePattern matches sops-encrypted values:
+// ENC[AES256_GCM,data:...,iv:...,tag:...,type:str]
+var sopsValuePattern = regexp.MustCompile(`ENC\[AES256_GCM,data:([^,]*),iv:([^,]+),tag:([^,]+),type:([a-z]+)\]`)
+
+// IsSops reports whether data is a sops-encrypted dotenv, YAML or JSON file
+func IsSops(data []byte) bool {
+	return bytes.Contains(data, []byte("sops_version=")) ||
+		bytes.Contains(data, []byte("sops:")) && bytes.Contains(data, []byte("ENC[AES256_GCM,")) ||
+		bytes.Contains(data, []byte(`"sops":`)) && bytes.Contains(data, []byte("ENC[AES256_GCM,"))
+}
+
+// ErrSopsMAC is returned when the MAC of a sops file doesn't match its
+// content, i.e. the file was modified without sops
+var ErrSopsMAC = errors.New("sops MAC mismatch: the file was modified outside of sops")
+
+// DecryptSops decrypts a sops file whose data key is encrypted to one of the
+// given age identity's recipients, returning the plaintext document without
+// the sops metadata. The sops MAC is verified unless skipMAC is set.
+func DecryptSops(data []byte, format parser.Format, identity string, skipMAC bool) ([]byte, error) {
+	switch format {
+	case parser.FormatDotenv:
+		return decryptSopsDotenv(data, identity, skipMAC)
+	case parser.FormatYAML, parser.FormatJSON:
+		return decryptSopsTree(data, format, identity, skipMAC)
+	default:
+		return nil, fmt.Errorf("unsupported sops format: %s (supported: dotenv, yaml, json)", format)
+	}
+}
+
+// sopsMAC recomputes the MAC sops keeps over a file: SHA-512 of all values
+// and comments in file order, as sops stores them (bools as True/False)
+type sopsMAC struct {
+	hash          hash.Hash
+	onlyEncrypted bool // mac_only_encrypted: unencrypted values aren't covered
+}
+
+func newSopsMAC(onlyEncrypted bool) *sopsMAC {
+	return &sopsMAC{hash: sha512.New(), onlyEncrypted: onlyEncrypted}
+}
+
+func (m *sopsMAC) add(value string, encrypted bool) {
+	if encrypted || !m.onlyEncrypted {
+		m.hash.Write([]byte(value))
+	}
+}
+
+// verify compares the MAC with the encrypted mac from the metadata, whose
+// additional data is the lastmodified timestamp
+func (m *sopsMAC) verify(encMAC, lastModified string, key []byte) error {
+	if encMAC == "" {
+		return fmt.Errorf("%w (no MAC in sops metadata)", ErrSopsMAC)
+	}
+	stored, _, err := sopsDecryptValue(encMAC, key, lastModified)
+	if err != nil {
+		return fmt.Errorf("%w (%v)", ErrSopsMAC, err)
+	}
+	if !strings.EqualFold(stored, fmt.Sprintf("%X", m.hash.Sum(nil))) {
+		return ErrSopsMAC
+	}
+	return nil
+}
+
+// sopsDataKey decrypts the 32-byte data key from the age stanzas in the metadata
+func sopsDataKey(encKeys []string, identity string) ([]byte, error) {
+	if len(encKeys) == 0 {
+		return nil, fmt.Errorf("no age recipients in sops metadata (only age-encrypted sops files are supported)")
+	}
+
+	var lastErr error
+	for _, enc := range encKeys {
+		key, err := crypto.AgeDecrypt([]byte(enc), identity)
+		if err == nil {
+			return key, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("none of the sops age recipients match your identity: %w", lastErr)
+}
+
+// sopsDecryptValue decrypts one ENC[AES256_GCM,...] value. additionalData is
+// the key path joined with ':' and a trailing ':' (e.g. "db:password:").
+func sopsDecryptValue(value string, key []byte, additionalData string) (string, string, error) {
+	m := sopsValuePattern.FindStringSubmatch(value)
+	if m == nil {
+		return "", "", fmt.Errorf("not a sops value")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(m[1])
+	if err != nil {
+		return "", "", err
+	}
+	iv, err := base64.StdEncoding.DecodeString(m[2])
+	if err != nil {
+		return "", "", err
+	}
+	tag, err := base64.StdEncoding.DecodeString(m[3])
+	if err != nil {
+		return "", "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", "", err
+	}
+
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return "", "", fmt.Errorf("decryption failed: %w", err)
+	}
+	return string(plaintext), m[4], nil
+}
+
+// decryptSopsComment decrypts sops-encrypted comments found in text
+func decryptSopsComment(text string, key []byte, path string) (string, error) {
+	var firstErr error
+	out := sopsValuePattern.ReplaceAllStringFunc(text, func(enc string) string {
+		// sops versions differ in the additional data used for comments
+		for _, aad := range []string{"", path} {
+			if plaintext, _, err := sopsDecryptValue(enc, key, aad); err == nil {
+				return plaintext
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("failed to decrypt comment")
+		}
+		return enc
+	})
+	return out, firstErr
+}
+
+func decryptSopsDotenv(data []byte, identity string, skipMAC bool) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+
+	// Collect metadata (sops_age__list_N__map_enc=...) first
+	encByIndex := map[string]string{}
+	meta := map[string]string{}
+	for _, line := range lines {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || !strings.HasPrefix(k, "sops_") {
+			continue
+		}
+		if strings.HasPrefix(k, "sops_age__list_") && strings.HasSuffix(k, "__map_enc") {
+			encByIndex[k] = strings.ReplaceAll(v, `\n`, "\n")
+		}
+		meta[k] = v
+	}
+	var names []string
+	for k := range encByIndex {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var encKeys []string
+	for _, k := range names {
+		encKeys = append(encKeys, encByIndex[k])
+	}
+
+	key, err := sopsDataKey(encKeys, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := newSopsMAC(meta["sops_mac_only_encrypted"] == "true")
+	var result []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "sops_") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") {
+			comment, err := decryptSopsComment(line, key, ":")
+			if err != nil {
+				return nil, err
+			}
+			mac.add(comment[1:], sopsValuePattern.MatchString(line))
+			result = append(result, comment)
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || !sopsValuePattern.MatchString(v) {
+			if ok {
+				mac.add(strings.ReplaceAll(v, `\n`, "\n"), false)
+			}
+			result = append(result, line)
+			continue
+		}
+
+		plaintext, _, err := sopsDecryptValue(v, key, k+":")
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key '%s': %w", k, err)
+		}
+		mac.add(plaintext, true)
+		// sops dotenv files store newlines escaped
+		result = append(result, k+"="+strings.ReplaceAll(plaintext, "\n", `\n`))
+	}
+
+	if !skipMAC {
+		if err := mac.verify(meta["sops_mac"], meta["sops_lastmodified"], key); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(strings.Join(result, "\n")), nil
+}
+
+func decryptSopsTree(data []byte, format parser.Format, identity string, skipMAC bool) ([]byte, error) {
+	// JSON is valid YAML, so both go through the yaml.v3 node API
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", format, err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("sops file must contain a mapping")
+	}
+	root := doc.Content[0]
+
+	// Take the sops metadata out of the document
+	var meta *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "sops" {
+			meta = root.Content[i+1]
+			root.Content = append(root.Content[:i], root.Content[i+2:]...)
+			break
+		}
+	}
+	if meta == nil {
+		return nil, fmt.Errorf("no sops metadata found")
+	}
+
+	var metadata struct {
+		Age []struct {
+			Recipient string `yaml:"recipient"`
+			Enc       string `yaml:"enc"`
+		} `yaml:"age"`
+		MAC              string `yaml:"mac"`
+		LastModified     string `yaml:"lastmodified"`
+		MACOnlyEncrypted bool   `yaml:"mac_only_encrypted"`
+	}
+	if err := meta.Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("invalid sops metadata: %w", err)
+	}
+	var encKeys []string
+	for _, a := range metadata.Age {
+		encKeys = append(encKeys, a.Enc)
+	}
+
+	key, err := sopsDataKey(encKeys, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := map[*yaml.Node]bool{}
+	if err := decryptSopsNode(root, nil, key, encrypted); err != nil {
+		return nil, err
+	}
+
+	if !skipMAC {
+		mac := newSopsMAC(metadata.MACOnlyEncrypted)
+		mac.addNode(&doc, false, encrypted)
+		if err := mac.verify(metadata.MAC, metadata.LastModified, key); err != nil {
+			return nil, err
+		}
+	}
+
+	if format == parser.FormatJSON {
+		var buf bytes.Buffer
+		if err := writeJSONNode(&buf, root, ""); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+		return buf.Bytes(), nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decryptSopsNode decrypts values in place, recording the nodes that were
+// encrypted. As in sops, list items share the path of their parent key.
+func decryptSopsNode(node *yaml.Node, path []string, key []byte, encrypted map[*yaml.Node]bool) error {
+	pathString := strings.Join(path, ":") + ":"
+
+	for _, c := range []*string{&node.HeadComment, &node.LineComment, &node.FootComment} {
+		if strings.Contains(*c, "ENC[AES256_GCM,") {
+			comment, err := decryptSopsComment(*c, key, pathString)
+			if err != nil {
+				return err
+			}
+			*c = comment
+			encrypted[node] = true
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := decryptSopsNode(node.Content[i], path, key, encrypted); err != nil {
+				return err
+			}
+			if err := decryptSopsNode(node.Content[i+1], append(path, node.Content[i].Value), key, encrypted); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := decryptSopsNode(child, path, key, encrypted); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !sopsValuePattern.MatchString(node.Value) {
+			return nil
+		}
+
+		plaintext, typ, err := sopsDecryptValue(node.Value, key, pathString)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt key '%s': %w", strings.Join(path, "."), err)
+		}
+
+		node.Value = plaintext
+		node.Style = 0
+		encrypted[node] = true
+		switch typ {
+		case "int", "float":
+			node.Tag = "!!" + typ
+		case "bool":
+			// sops stores bools as True/False, which isn't valid JSON
+			b, err := strconv.ParseBool(plaintext)
+			if err != nil {
+				return fmt.Errorf("invalid bool in key '%s': %w", strings.Join(path, "."), err)
+			}
+			node.Value = strconv.FormatBool(b)
+			node.Tag = "!!bool"
+		default:
+			node.Tag = "!!str"
+		}
+	}
+
+	return nil
+}
+
+// addNode hashes a decrypted node in the order sops' YAML store puts values
+// and comments in its tree. commentsDone is set when the caller already
+// handled the node's comments.
+func (m *sopsMAC) addNode(node *yaml.Node, commentsDone bool, encrypted map[*yaml.Node]bool) {
+	if !commentsDone {
+		m.addComments(node.HeadComment, encrypted[node])
+		m.addComments(node.LineComment, encrypted[node])
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			m.addNode(child, false, encrypted)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			m.addComments(k.HeadComment, encrypted[k])
+			m.addComments(k.LineComment, encrypted[k])
+			leaf := v.Kind == yaml.ScalarNode || v.Kind == yaml.AliasNode
+			if leaf {
+				m.addComments(v.HeadComment, encrypted[v])
+				m.addComments(v.LineComment, encrypted[v])
+			}
+			m.addValue(v, encrypted)
+			if leaf {
+				m.addComments(v.FootComment, encrypted[v])
+			}
+			m.addComments(k.FootComment, encrypted[k])
+		}
+	default:
+		m.addValue(node, encrypted)
+	}
+
+	if !commentsDone {
+		m.addComments(node.FootComment, encrypted[node])
+	}
+}
+
+func (m *sopsMAC) addValue(node *yaml.Node, encrypted map[*yaml.Node]bool) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		m.addNode(node, true, encrypted)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			m.addComments(item.HeadComment, encrypted[item])
+			m.addComments(item.LineComment, encrypted[item])
+			m.addValue(item, encrypted)
+			m.addComments(item.FootComment, encrypted[item])
+		}
+	case yaml.AliasNode:
+		m.addValue(node.Alias, encrypted)
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return
+		}
+		m.add(sopsBytes(node), encrypted[node])
+	}
+}
+
+// addComments hashes each comment line without its '#'
+func (m *sopsMAC) addComments(comment string, encrypted bool) {
+	for _, line := range strings.Split(comment, "\n") {
+		if line != "" {
+			m.add(line[1:], encrypted)
+		}
+	}
+}
+
+// sopsBytes formats a scalar the way sops hashes it
+func sopsBytes(node *yaml.Node) string {
+	switch node.Tag {
+	case "!!bool":
+		if b, err := strconv.ParseBool(node.Value); err == nil {
+			if b {
+				return "True"
+			}
+			return "False"
+		}
+	case "!!int":
+		if i, err := strconv.ParseInt(node.Value, 0, 64); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+	case "!!float":
+		if f, err := strconv.ParseFloat(node.Value, 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	}
+	return node.Value
+}
+
+// writeJSONNode writes a yaml.v3 node tree as indented JSON, keeping key order
+func writeJSONNode(buf *bytes.Buffer, node *yaml.Node, indent string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{")
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			key, _ := json.Marshal(node.Content[i].Value)
+			fmt.Fprintf(buf, "\n%s  %s: ", indent, key)
+			if err := writeJSONNode(buf, node.Content[i+1], indent+"  "); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[")
+		for i, child := range node.Content {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + indent + "  ")
+			if err := writeJSONNode(buf, child, indent+"  "); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "]")
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!int", "!!float", "!!bool", "!!null":
+			buf.WriteString(node.Value)
+		default:
+			value, _ := json.Marshal(node.Value)
+			buf.Write(value)
+		}
+	default:
+		return fmt.Errorf("unsupported JSON node")
+	}
+	return nil
+}
+
+// SopsPlainPath suggests where the decrypted file should live, dropping the
+// .enc / .sops infix common for sops files (secrets.enc.yaml → secrets.yaml)
+func SopsPlainPath(path string) string {
+	for _, infix := range []string{".enc.", ".sops."} {
+		if i := strings.LastIndex(path, infix); i != -1 {
+			return path[:i] + path[i+len(infix)-1:]
+		}
+	}
+	return path
+}
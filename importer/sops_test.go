@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"filippo.io/age/armor"
+
+	"github.com/hades/podx/crypto"
+	"github.com/hades/podx/parser"
+)
+
+// sopsFixture builds sops files the way sops does: AES-256-GCM values with
+// the key path as additional data, and the data key encrypted with age
+type sopsFixture struct {
+	t        *testing.T
+	key      []byte
+	identity string
+	enc      string // Data key encrypted to the identity
+}
+
+func newSopsFixture(t *testing.T) *sopsFixture {
+	t.Helper()
+	identity, recipient, err := crypto.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	enc, err := crypto.AgeEncrypt(key, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sops stores the data key ASCII-armored
+	var armored bytes.Buffer
+	w := armor.NewWriter(&armored)
+	w.Write(enc)
+	w.Close()
+	return &sopsFixture{t: t, key: key, identity: identity, enc: armored.String()}
+}
+
+func (f *sopsFixture) value(plaintext, aad, typ string) string {
+	block, err := aes.NewCipher(f.key)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, 32)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	iv := make([]byte, 32)
+	rand.Read(iv)
+	sealed := gcm.Seal(nil, iv, []byte(plaintext), []byte(aad))
+	data, tag := sealed[:len(sealed)-16], sealed[len(sealed)-16:]
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data), base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag), typ)
+}
+
+// mac returns the encrypted MAC over values, as stored in the metadata
+func (f *sopsFixture) mac(lastModified string, values ...string) string {
+	h := sha512.New()
+	for _, v := range values {
+		h.Write([]byte(v))
+	}
+	return f.value(fmt.Sprintf("%X", h.Sum(nil)), lastModified, "str")
+}
+
+const lastModified = "2024-05-01T10:00:00Z"
+
+func (f *sopsFixture) yaml(values map[string]string, mac string) string {
+	return fmt.Sprintf(`db:
+    user: %s
+    port: %s
+    tls: %s
+    region_unencrypted: eu
+sops:
+    age:
+        - recipient: age1test
+          enc: |
+%s
+    lastmodified: "%s"
+    mac: %s
+    version: 3.8.1
+`, values["user"], values["port"], values["tls"], indent(f.enc, "            "), lastModified, mac)
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestDecryptSopsYAML(t *testing.T) {
+	f := newSopsFixture(t)
+	values := map[string]string{
+		"user": f.value("admin", "db:user:", "str"),
+		"port": f.value("5432", "db:port:", "int"),
+		"tls":  f.value("True", "db:tls:", "bool"),
+	}
+	mac := f.mac(lastModified, "admin", "5432", "True", "eu")
+	data := []byte(f.yaml(values, mac))
+
+	if !IsSops(data) {
+		t.Fatal("not detected as sops")
+	}
+
+	out, err := DecryptSops(data, parser.FormatYAML, f.identity, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "db:\n  user: admin\n  port: 5432\n  tls: true\n  region_unencrypted: eu\n"
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = DecryptSops(data, parser.FormatJSON, f.identity, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(out) {
+		t.Errorf("invalid JSON:\n%s", out)
+	}
+	if !strings.Contains(string(out), `"tls": true`) {
+		t.Errorf("bool not normalized:\n%s", out)
+	}
+}
+
+func TestDecryptSopsYAMLTampered(t *testing.T) {
+	f := newSopsFixture(t)
+	values := map[string]string{
+		"user": f.value("admin", "db:user:", "str"),
+		"port": f.value("5432", "db:port:", "int"),
+		"tls":  f.value("True", "db:tls:", "bool"),
+	}
+	mac := f.mac(lastModified, "admin", "5432", "True", "eu")
+	data := strings.Replace(f.yaml(values, mac), "region_unencrypted: eu", "region_unencrypted: us", 1)
+
+	_, err := DecryptSops([]byte(data), parser.FormatYAML, f.identity, false)
+	if !errors.Is(err, ErrSopsMAC) {
+		t.Fatalf("expected MAC error, got %v", err)
+	}
+	if _, err := DecryptSops([]byte(data), parser.FormatYAML, f.identity, true); err != nil {
+		t.Fatalf("skipMAC: %v", err)
+	}
+}
+
+func TestDecryptSopsDotenv(t *testing.T) {
+	f := newSopsFixture(t)
+	enc := strings.ReplaceAll(strings.TrimSpace(f.enc), "\n", `\n`)
+	lines := []string{
+		"#" + f.value(" database", ":", "comment"),
+		"DB_USER=" + f.value("admin", "DB_USER:", "str"),
+		"DB_PASS=" + f.value("s3cr\net", "DB_PASS:", "str"),
+		"sops_age__list_0__map_enc=" + enc,
+		"sops_age__list_0__map_recipient=age1test",
+		"sops_lastmodified=" + lastModified,
+		"sops_mac=" + f.mac(lastModified, " database", "admin", "s3cr\net"),
+		"sops_version=3.8.1",
+	}
+	data := []byte(strings.Join(lines, "\n") + "\n")
+
+	out, err := DecryptSops(data, parser.FormatDotenv, f.identity, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# database\nDB_USER=admin\nDB_PASS=s3cr\\net\n"
+	if string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+
+	// Dropping a line is caught by the MAC
+	tampered := strings.Replace(string(data), lines[1]+"\n", "", 1)
+	if _, err := DecryptSops([]byte(tampered), parser.FormatDotenv, f.identity, false); !errors.Is(err, ErrSopsMAC) {
+		t.Fatalf("expected MAC error, got %v", err)
+	}
+}
+
+func TestIsDotenvVault(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"#/---.env.vault---/\nDOTENV_VAULT_DEVELOPMENT=\"AtEC33ZfFJQMSE6C+EP5/T4jRGw3aMAE==\"\n", true},
+		{"DOTENV_VAULT_PRODUCTION=\"abc+/=\"\r\n", true},
+		{"# Mentions DOTENV_VAULT_DEVELOPMENT in a comment\nA=1\n", false},
+		{"DOTENV_VAULT_URL=https://example.com\n", false},
+	}
+	for _, tt := range tests {
+		if got := IsDotenvVault([]byte(tt.data)); got != tt.want {
+			t.Errorf("IsDotenvVault(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
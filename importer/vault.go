@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hades/podx/parser"
+)
+
+// vaultLinePattern matches an environment line of a .env.vault file:
+// DOTENV_VAULT_PRODUCTION="<base64 nonce+ciphertext>"
+var vaultLinePattern = regexp.MustCompile(`(?m)^DOTENV_VAULT_[A-Z0-9_]+="[A-Za-z0-9+/]+={0,2}"\r?$`)
+
+// IsDotenvVault reports whether data is a dotenv-vault .env.vault file
+func IsDotenvVault(data []byte) bool {
+	return vaultLinePattern.Match(data)
+}
+
+// DecryptDotenvVault decrypts one environment of a .env.vault file with a
+// DOTENV_KEY (dotenv://:key_<hex>@dotenv.org/vault/.env.vault?environment=NAME).
+// It returns the environment name and the plaintext .env content.
+func DecryptDotenvVault(data []byte, dotenvKey string) (string, []byte, error) {
+	u, err := url.Parse(strings.TrimSpace(dotenvKey))
+	if err != nil || u.User == nil {
+		return "", nil, fmt.Errorf("invalid DOTENV_KEY (expected dotenv://:key_...@dotenv.org/vault/.env.vault?environment=...)")
+	}
+
+	password, _ := u.User.Password()
+	key, err := hex.DecodeString(strings.TrimPrefix(password, "key_"))
+	if err != nil || len(key) != 32 {
+		return "", nil, fmt.Errorf("invalid DOTENV_KEY: key must be 64 hex characters")
+	}
+
+	environment := u.Query().Get("environment")
+	if environment == "" {
+		return "", nil, fmt.Errorf("invalid DOTENV_KEY: missing environment")
+	}
+
+	entries, err := parser.ParseEnv(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := "DOTENV_VAULT_" + strings.ToUpper(environment)
+	var encoded string
+	for _, v := range parser.EnvVars(entries) {
+		if v.Key == name {
+			encoded = v.Value
+		}
+	}
+	if encoded == "" {
+		return "", nil, fmt.Errorf("environment '%s' not found in vault (%s)", environment, name)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid base64 in %s: %w", name, err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return "", nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("decryption failed (wrong DOTENV_KEY?): %w", err)
+	}
+
+	return environment, plaintext, nil
+}
@@ -1,17 +1,21 @@
 package keygen
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"gopkg.in/yaml.v3"
+
+	"github.com/hades/podx/config"
 	"github.com/hades/podx/crypto"
 )
 
 const (
-	configDir      = ".config/podx"
 	ageKeysFile    = "age-keys.txt"
 	ageRecipientsDir = "age-recipients"
 )
@@ -19,19 +23,18 @@ const (
 // KeygenResult contains the result of key generation
 type KeygenResult struct {
 	Backend     string
+	Name        string // Key name (age)
+	Active      bool   // Whether the key is the active key (age)
+	Protected   bool   // Whether the key file is passphrase-protected (age)
 	KeyFile     string
 	PublicKey   string
 	PrivateKey  string
 	Email       string
 }
 
-// GetConfigDir returns the podx config directory
+// GetConfigDir returns the podx config directory (see config.Dir)
 func GetConfigDir() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
-	}
-	return filepath.Join(home, configDir), nil
+	return config.Dir()
 }
 
 // EnsureConfigDir creates the config directory if not exists
@@ -54,47 +57,55 @@ func EnsureConfigDir() (string, error) {
 	return dir, nil
 }
 
-// GenerateAge generates a new Age key pair and saves it
-func GenerateAge() (*KeygenResult, error) {
-	privateKey, publicKey, err := crypto.GenerateAgeKey()
+// GenerateAge generates a new named Age key pair and saves it. An empty
+// name picks "default" or the next free "key-N"; a non-empty passphrase
+// stores the key encrypted (see ProtectKey).
+func GenerateAge(name, comment, passphrase string) (*KeygenResult, error) {
+	privateKey, _, err := crypto.GenerateAgeKey()
 	if err != nil {
 		return nil, err
 	}
 
-	configDir, err := EnsureConfigDir()
+	return saveAgeKey(name, comment, privateKey, passphrase)
+}
+
+// GenerateAgePQ generates a post-quantum hybrid (ML-KEM-768 + X25519) Age
+// key pair and saves it like GenerateAge
+func GenerateAgePQ(name, comment, passphrase string) (*KeygenResult, error) {
+	privateKey, _, err := crypto.GenerateAgePQKey()
 	if err != nil {
 		return nil, err
 	}
 
-	keyFile := filepath.Join(configDir, ageKeysFile)
-
-	// Append to keys file with standard age format:
-	// # created: <timestamp>
-	// # public key: <public_key>
-	// AGE-SECRET-KEY-...
-	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", 
-		time.Now().Format(time.RFC3339), publicKey, privateKey)
+	return saveAgeKey(name, comment, privateKey, passphrase)
+}
 
-	f, err := os.OpenFile(keyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+// saveAgeKey stores the identity as a named key. It only becomes the active
+// key (used by podx init) when there was none; see UseKey.
+func saveAgeKey(name, comment, privateKey, passphrase string) (*KeygenResult, error) {
+	k, dir, err := loadKeyring()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open key file: %w", err)
+		return nil, err
 	}
-	defer f.Close()
 
-	if _, err := f.WriteString(content); err != nil {
-		return nil, fmt.Errorf("failed to write key: %w", err)
+	info, err := k.add(dir, name, comment, privateKey, passphrase, time.Now())
+	if err != nil {
+		return nil, err
 	}
-
-	// Save public key to recipients directory
-	pubKeyFile := filepath.Join(configDir, ageRecipientsDir, "default.txt")
-	if err := os.WriteFile(pubKeyFile, []byte(publicKey+"\n"), 0644); err != nil {
-		return nil, fmt.Errorf("failed to save public key: %w", err)
+	if k.Active == "" {
+		k.Active = info.Name
+	}
+	if err := k.save(dir); err != nil {
+		return nil, err
 	}
 
 	return &KeygenResult{
-		Backend:    "age",
-		KeyFile:    keyFile,
-		PublicKey:  publicKey,
+		Backend:    info.Type,
+		Name:       info.Name,
+		Active:     k.Active == info.Name,
+		Protected:  info.Protected,
+		KeyFile:    keyPath(dir, *info),
+		PublicKey:  info.PublicKey,
 		PrivateKey: privateKey,
 	}, nil
 }
@@ -117,37 +128,218 @@ func GenerateGPG(name, email string) (*KeygenResult, error) {
 	}, nil
 }
 
-// LoadAgeIdentity loads the Age private key from config
-func LoadAgeIdentity() (string, error) {
+// sshKeyFiles are the SSH private keys (relative to home) used as identities
+// when present, so SSH public keys work as project recipients. Keys age
+// can't use are skipped with a warning.
+var sshKeyFiles = []string{".ssh/id_ed25519", ".ssh/id_rsa"}
+
+// identityFiles are extra identity files given with -i/--identity
+var identityFiles []string
+
+// AddIdentityFile adds an identity file (age keys, plugin identities or an
+// SSH private key) to try when decrypting
+func AddIdentityFile(path string) {
+	identityFiles = append(identityFiles, path)
+}
+
+// identitySource is one place identities were loaded from
+type identitySource struct {
+	Name string
+	Data string
+	Err  error // Provider that failed to load; reported only if nothing else decrypts
+}
+
+// loadIdentitySources collects identities in the order they are tried:
+// podx agent (PODX_AGENT_SOCK), PODX_AGE_KEY, -i/--identity files,
+// PODX_AGE_KEY_FILE, SOPS_AGE_KEY_FILE, identity providers (.podx.yaml, then
+// config.yaml, see providerSources), named keys, ~/.config/podx/age-keys.txt and the user's SSH keys
+func loadIdentitySources(projects []ProjectProviders) ([]identitySource, error) {
+	var sources []identitySource
+
+	// Tried first, so protected keys unlocked in the agent need no prompt
+	if socket := os.Getenv("PODX_AGENT_SOCK"); socket != "" {
+		sources = append(sources, identitySource{Name: "podx agent", Data: "PODX-AGENT " + socket})
+	}
+
+	if key := os.Getenv("PODX_AGE_KEY"); key != "" {
+		sources = append(sources, identitySource{Name: "PODX_AGE_KEY", Data: key})
+	}
+
+	explicit := append([]string{}, identityFiles...)
+	for _, env := range []string{"PODX_AGE_KEY_FILE", "SOPS_AGE_KEY_FILE"} {
+		if path := os.Getenv(env); path != "" {
+			explicit = append(explicit, path)
+		}
+	}
+	for _, path := range explicit {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identity file: %w", err)
+		}
+		sources = append(sources, identitySource{Name: path, Data: string(data)})
+	}
+
+	providers, err := providerSources(projects)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range providers {
+		data, err := p.load()
+		if err != nil {
+			// A failing provider (password manager locked, command missing)
+			// only matters if no other identity can decrypt
+			if !p.Optional {
+				sources = append(sources, identitySource{Name: p.describe(), Err: err})
+			}
+			continue
+		}
+		sources = append(sources, identitySource{Name: p.describe(), Data: data})
+	}
+
 	configDir, err := GetConfigDir()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// Named keys, the active one first
+	if data, err := os.ReadFile(filepath.Join(configDir, keyringFile)); err == nil {
+		var k keyring
+		if err := yaml.Unmarshal(data, &k); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", keyringFile, err)
+		}
+		// Passphrase-protected keys last, so there is no prompt when
+		// another key can decrypt
+		slices.SortStableFunc(k.Keys, func(a, b KeyInfo) int {
+			if a.Protected != b.Protected {
+				return boolToInt(a.Protected) - boolToInt(b.Protected)
+			}
+			return boolToInt(b.Name == k.Active) - boolToInt(a.Name == k.Active)
+		})
+		for _, info := range k.Keys {
+			data, err := os.ReadFile(keyPath(configDir, info))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read key '%s': %w", info.Name, err)
+			}
+			sources = append(sources, identitySource{Name: "key '" + info.Name + "'", Data: string(data)})
+		}
 	}
 
+	// Keys not yet migrated by 'podx key' (and plugin identities added by hand)
 	keyFile := filepath.Join(configDir, ageKeysFile)
 	data, err := os.ReadFile(keyFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read age key file: %w", err)
+	}
+	if hasIdentity(string(data)) {
+		sources = append(sources, identitySource{Name: keyFile, Data: string(data)})
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range sshKeyFiles {
+			path := filepath.Join(home, name)
+			pem, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			// The public key is needed for encrypted keys in old formats
+			content := string(pem)
+			if pub, err := os.ReadFile(path + ".pub"); err == nil {
+				content = "# public key: " + strings.TrimSpace(string(pub)) + "\n" + content
+			}
+			// Unlike keys the user named, an SSH key podx merely found must
+			// not stop decryption when age can't use it
+			if _, err := crypto.LoadIdentities("# ~/" + name + "\n" + content); err != nil {
+				warnSkippedSSHKey(name, err)
+				continue
+			}
+			sources = append(sources, identitySource{Name: "~/" + name, Data: content})
+		}
+	}
+
+	return sources, nil
+}
+
+// skippedSSHKeys remembers the SSH keys already warned about, since the
+// identities are loaded more than once per command
+var skippedSSHKeys = map[string]bool{}
+
+func warnSkippedSSHKey(name string, err error) {
+	if skippedSSHKeys[name] {
+		return
+	}
+	skippedSSHKeys[name] = true
+	fmt.Fprintf(os.Stderr, "⚠️  Skipping ~/%s: %v\n", name, err)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// hasIdentity reports whether data contains an age, plugin or SSH identity,
+// a passphrase-protected identity file or a podx agent
+func hasIdentity(data string) bool {
+	return strings.Contains(data, "AGE-SECRET-KEY-") ||
+		strings.Contains(data, "AGE-PLUGIN-") ||
+		strings.Contains(data, "PRIVATE KEY-----") ||
+		strings.Contains(data, "BEGIN AGE ENCRYPTED FILE") ||
+		strings.Contains(data, "PODX-AGENT ")
+}
+
+// LoadAgeIdentity loads every identity podx knows about (see
+// loadIdentitySources) as one identity string, including those of the
+// projects' providers (see project.Project.IdentityProviders).
+// crypto.AgeDecrypt tries each of them, so files encrypted to an older key
+// stay decryptable.
+func LoadAgeIdentity(projects ...ProjectProviders) (string, error) {
+	sources, err := loadIdentitySources(projects)
 	if err != nil {
-		return "", fmt.Errorf("failed to read age key file: %w", err)
+		return "", err
 	}
 
-	// Parse the key file - find the last valid identity
-	lines := strings.Split(string(data), "\n")
-	var identity string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "AGE-SECRET-KEY-") {
-			identity = line
+	var sb strings.Builder
+	var failed []error
+	for _, src := range sources {
+		if src.Err != nil {
+			failed = append(failed, src.Err)
+			continue
 		}
+		// The "# <source>" comment names SSH keys in passphrase prompts
+		sb.WriteString("# " + src.Name + "\n" + strings.TrimSpace(src.Data) + "\n")
 	}
 
-	if identity == "" {
-		return "", fmt.Errorf("no age identity found in %s", keyFile)
+	if !hasIdentity(sb.String()) {
+		if len(failed) > 0 {
+			return "", errors.Join(failed...)
+		}
+		return "", fmt.Errorf("no age identity found (generate one with 'podx keygen -t age', or use -i, PODX_AGE_KEY or ~/.ssh/id_ed25519)")
+	}
+	// Named in the error if no identity matches (see crypto.AgeDecrypt)
+	for _, err := range failed {
+		sb.WriteString(crypto.UnavailableLine(err) + "\n")
 	}
+	return sb.String(), nil
+}
+
+// IdentitySources lists where LoadAgeIdentity found identities, for errors
+func IdentitySources(projects ...ProjectProviders) []string {
+	sources, _ := loadIdentitySources(projects)
 
-	return identity, nil
+	var names []string
+	for _, src := range sources {
+		if src.Err != nil {
+			names = append(names, src.Err.Error())
+			continue
+		}
+		names = append(names, src.Name)
+	}
+	return names
 }
 
-// LoadAgeRecipient loads the Age public key from config
+// LoadAgeRecipient loads the Age public key from config, falling back to
+// the user's SSH public key
 func LoadAgeRecipient() (string, error) {
 	configDir, err := GetConfigDir()
 	if err != nil {
@@ -157,6 +349,13 @@ func LoadAgeRecipient() (string, error) {
 	pubKeyFile := filepath.Join(configDir, ageRecipientsDir, "default.txt")
 	data, err := os.ReadFile(pubKeyFile)
 	if err != nil {
+		if home, herr := os.UserHomeDir(); herr == nil {
+			for _, name := range sshKeyFiles {
+				if pub, perr := os.ReadFile(filepath.Join(home, name+".pub")); perr == nil {
+					return strings.TrimSpace(string(pub)), nil
+				}
+			}
+		}
 		return "", fmt.Errorf("failed to read age public key: %w", err)
 	}
 
@@ -180,6 +379,9 @@ func PrintKeygenResult(result *KeygenResult) {
 	
 	// Backend
 	printRow("Backend:", result.Backend, width)
+	if result.Name != "" {
+		printRow("Name:", result.Name, width)
+	}
 	
 	// Key file (for Age)
 	if result.KeyFile != "" {
@@ -199,25 +401,46 @@ func PrintKeygenResult(result *KeygenResult) {
 	// Separator
 	fmt.Printf("╠%s╣\n", strings.Repeat("═", width))
 	
-	// Keys section for Age
-	if result.Backend == "age" {
-		printRow("Public Key:", "", width)
-		printRow("  "+result.PublicKey, "", width)
+	// Keys section for Age. Keys too long for the box (private keys,
+	// post-quantum public keys) are printed in full below it.
+	var below []string
+	keyRow := func(label, key string) {
+		printRow(label, "", width)
+		if len(key)+2 > width-2 {
+			printRow("  (printed below)", "", width)
+			below = append(below, label+"\n"+key)
+		} else {
+			printRow("  "+key, "", width)
+		}
+	}
+	if strings.HasPrefix(result.Backend, "age") {
+		keyRow("Public Key:", result.PublicKey)
 		fmt.Printf("║%s║\n", strings.Repeat(" ", width))
-		printRow("Private Key:", "", width)
-		printRow("  "+result.PrivateKey, "", width)
+		if result.Protected {
+			printRow("Private Key:", "(passphrase-protected)", width)
+		} else {
+			keyRow("Private Key:", result.PrivateKey)
+		}
 	} else {
 		printRow("Key ID:", result.PublicKey, width)
 	}
 	
 	// Bottom border
 	fmt.Printf("╚%s╝\n", strings.Repeat("═", width))
+	for _, key := range below {
+		fmt.Printf("\n%s\n", key)
+	}
 	
 	// Additional info
-	if result.Backend == "age" {
+	if strings.HasPrefix(result.Backend, "age") {
 		fmt.Println()
-		fmt.Println("📋 Public key copied to: ~/.config/podx/age-recipients/default.txt")
-		fmt.Println("🔐 Private key saved to: ~/.config/podx/age-keys.txt")
+		fmt.Printf("📤 Print the public key again with: podx key export %s\n", result.Name)
+		fmt.Printf("🔐 Private key saved as '%s'\n", result.Name)
+		if result.Active {
+			fmt.Println("📋 This is your active key, used by 'podx init'")
+		} else {
+			fmt.Printf("📋 Make it your active key with: podx key use %s\n", result.Name)
+		}
 	}
 }
 
@@ -0,0 +1,466 @@
+package keygen
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hades/podx/crypto"
+)
+
+const (
+	keysDir      = "keys"      // One identity file per named key
+	keyringFile  = "keys.yaml" // Key metadata and the active key
+	projectsFile = "projects.txt"
+)
+
+// KeyInfo is the metadata of a named key
+type KeyInfo struct {
+	Name        string    `yaml:"name"`
+	Type        string    `yaml:"type"` // age or age-pq
+	PublicKey   string    `yaml:"public_key"`
+	Fingerprint string    `yaml:"fingerprint"`
+	Created     time.Time `yaml:"created"`
+	Comment     string    `yaml:"comment,omitempty"`
+	Protected   bool      `yaml:"protected,omitempty"` // Stored as keys/<name>.age
+}
+
+// keyring is the content of keys.yaml
+type keyring struct {
+	Active string    `yaml:"active,omitempty"`
+	Keys   []KeyInfo `yaml:"keys"`
+}
+
+func (k *keyring) find(name string) int {
+	return slices.IndexFunc(k.Keys, func(info KeyInfo) bool { return info.Name == name })
+}
+
+// hasPrivateKey reports whether the keyring already holds privateKey
+func (k *keyring) hasPrivateKey(privateKey string) bool {
+	publicKey, err := crypto.AgePublicKey(privateKey)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(k.Keys, func(info KeyInfo) bool { return info.PublicKey == publicKey })
+}
+
+// verifyMigrated checks that each private key was written to its key file
+func (k *keyring) verifyMigrated(dir string, privateKeys []string) error {
+	for _, privateKey := range privateKeys {
+		publicKey, err := crypto.AgePublicKey(privateKey)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(k.Keys, func(info KeyInfo) bool { return info.PublicKey == publicKey })
+		if i < 0 {
+			return fmt.Errorf("key %s missing from %s", publicKey, keyringFile)
+		}
+		data, err := os.ReadFile(keyPath(dir, k.Keys[i]))
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(data), privateKey) {
+			return fmt.Errorf("key file of '%s' does not hold its private key", k.Keys[i].Name)
+		}
+	}
+	return nil
+}
+
+// Fingerprint returns a short, stable identifier of a public key
+func Fingerprint(publicKey string) string {
+	sum := sha256.Sum256([]byte(publicKey))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])[:16]
+}
+
+// loadKeyring reads keys.yaml. The first time, keys from the legacy
+// age-keys.txt are moved into named key files (default, key-2, ...). Once
+// every key file is verified, the plaintext secret keys are removed from
+// age-keys.txt so no stray copy is left behind.
+func loadKeyring() (*keyring, string, error) {
+	dir, err := EnsureConfigDir()
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, keyringFile))
+	if err == nil {
+		var k keyring
+		if err := yaml.Unmarshal(data, &k); err != nil {
+			return nil, "", fmt.Errorf("invalid %s: %w", keyringFile, err)
+		}
+		return &k, dir, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, "", err
+	}
+
+	k := &keyring{}
+	legacy := filepath.Join(dir, ageKeysFile)
+	if data, err := os.ReadFile(legacy); err == nil {
+		defaultKey, _ := os.ReadFile(filepath.Join(dir, ageRecipientsDir, "default.txt"))
+
+		var secretKeys, plugins []string
+		for _, line := range strings.Split(string(data), "\n") {
+			switch line = strings.TrimSpace(line); {
+			case strings.HasPrefix(line, "AGE-SECRET-KEY-"):
+				secretKeys = append(secretKeys, line)
+			case strings.HasPrefix(line, "AGE-PLUGIN-"):
+				plugins = append(plugins, line)
+			}
+		}
+
+		for _, line := range secretKeys {
+			// A key listed twice is migrated once
+			if k.hasPrivateKey(line) {
+				continue
+			}
+			info, err := k.add(dir, "", "", line, "", time.Now())
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to migrate %s: %w", legacy, err)
+			}
+			if info.PublicKey == strings.TrimSpace(string(defaultKey)) || k.Active == "" {
+				k.Active = info.Name
+			}
+		}
+
+		if err := k.save(dir); err != nil {
+			return nil, "", err
+		}
+		if err := k.verifyMigrated(dir, secretKeys); err != nil {
+			return nil, "", fmt.Errorf("failed to migrate %s (left unchanged): %w", legacy, err)
+		}
+
+		// Plugin identities can't be named without running the plugin, so
+		// they stay in age-keys.txt
+		if len(plugins) > 0 {
+			err = os.WriteFile(legacy, []byte(strings.Join(plugins, "\n")+"\n"), 0600)
+		} else {
+			err = os.Remove(legacy)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return k, dir, nil
+	}
+
+	return k, dir, nil
+}
+
+func (k *keyring) save(dir string) error {
+	data, err := yaml.Marshal(k)
+	if err != nil {
+		return fmt.Errorf("failed to marshal keys: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, keyringFile), data, 0600); err != nil {
+		return err
+	}
+
+	// Keep age-recipients/default.txt pointing at the active key for
+	// anything that still reads it
+	pubKeyFile := filepath.Join(dir, ageRecipientsDir, "default.txt")
+	if i := k.find(k.Active); i >= 0 {
+		return os.WriteFile(pubKeyFile, []byte(k.Keys[i].PublicKey+"\n"), 0644)
+	}
+	if err := os.Remove(pubKeyFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// add writes a private key to keys/<name>.txt (or, with a passphrase,
+// keys/<name>.age) and records it. An empty name picks "default" or the next
+// free "key-N".
+func (k *keyring) add(dir, name, comment, privateKey, passphrase string, created time.Time) (*KeyInfo, error) {
+	publicKey, err := crypto.AgePublicKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range k.Keys {
+		if info.PublicKey == publicKey {
+			return nil, fmt.Errorf("key already exists as '%s'", info.Name)
+		}
+	}
+
+	if name == "" {
+		name = "default"
+		for n := 2; k.find(name) >= 0; n++ {
+			name = fmt.Sprintf("key-%d", n)
+		}
+	}
+	if err := validKeyName(name); err != nil {
+		return nil, err
+	}
+	if k.find(name) >= 0 {
+		return nil, fmt.Errorf("a key named '%s' already exists", name)
+	}
+
+	keyType := "age"
+	if crypto.IsPQRecipient(publicKey) {
+		keyType = "age-pq"
+	}
+
+	// Standard age identity file format
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		created.Format(time.RFC3339), publicKey, privateKey)
+	info := KeyInfo{
+		Name:        name,
+		Type:        keyType,
+		PublicKey:   publicKey,
+		Fingerprint: Fingerprint(publicKey),
+		Created:     created.UTC().Truncate(time.Second),
+		Comment:     comment,
+	}
+	if err := writeKeyFile(dir, &info, []byte(content), passphrase); err != nil {
+		return nil, err
+	}
+
+	k.Keys = append(k.Keys, info)
+	return &k.Keys[len(k.Keys)-1], nil
+}
+
+// writeKeyFile stores an identity file for info, encrypted with passphrase
+// if given, and removes the file in the other format
+func writeKeyFile(dir string, info *KeyInfo, content []byte, passphrase string) error {
+	if err := os.MkdirAll(filepath.Join(dir, keysDir), 0700); err != nil {
+		return err
+	}
+
+	old := keyPath(dir, *info)
+	info.Protected = passphrase != ""
+	if info.Protected {
+		var err error
+		if content, err = crypto.ProtectIdentity(content, passphrase); err != nil {
+			return err
+		}
+	}
+
+	path := keyPath(dir, *info)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	if old != path {
+		if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func keyPath(dir string, info KeyInfo) string {
+	if info.Protected {
+		return filepath.Join(dir, keysDir, info.Name+".age")
+	}
+	return filepath.Join(dir, keysDir, info.Name+".txt")
+}
+
+func validKeyName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\:`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid key name '%s'", name)
+	}
+	return nil
+}
+
+// ListKeys returns all named keys and the name of the active one
+func ListKeys() ([]KeyInfo, string, error) {
+	k, _, err := loadKeyring()
+	if err != nil {
+		return nil, "", err
+	}
+	return k.Keys, k.Active, nil
+}
+
+// UseKey makes name the active key, used as the owner recipient by podx init
+func UseKey(name string) error {
+	k, dir, err := loadKeyring()
+	if err != nil {
+		return err
+	}
+	if k.find(name) < 0 {
+		return fmt.Errorf("no key named '%s'", name)
+	}
+	k.Active = name
+	return k.save(dir)
+}
+
+// ExportKey returns the public key of name, or of the active key if empty
+func ExportKey(name string) (string, error) {
+	k, _, err := loadKeyring()
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = k.Active
+	}
+	i := k.find(name)
+	if i < 0 {
+		return "", fmt.Errorf("no key named '%s'", name)
+	}
+	return k.Keys[i].PublicKey, nil
+}
+
+// ImportKeys imports the age identities in an existing key file. With several
+// keys in the file, the second and later ones get a -2, -3, ... suffix.
+func ImportKeys(path, name, comment string) ([]KeyInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	k, dir, err := loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	var imported []KeyInfo
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "AGE-SECRET-KEY-") {
+			continue
+		}
+
+		keyName := name
+		if name != "" && len(imported) > 0 {
+			keyName = fmt.Sprintf("%s-%d", name, len(imported)+1)
+		}
+		info, err := k.add(dir, keyName, comment, line, "", time.Now())
+		if err != nil {
+			return nil, err
+		}
+		imported = append(imported, *info)
+	}
+
+	if len(imported) == 0 {
+		return nil, fmt.Errorf("no AGE-SECRET-KEY found in %s", path)
+	}
+	if k.Active == "" {
+		k.Active = imported[0].Name
+	}
+	return imported, k.save(dir)
+}
+
+// ProtectKey encrypts the identity file of a named key with passphrase
+func ProtectKey(name, passphrase string) error {
+	k, dir, err := loadKeyring()
+	if err != nil {
+		return err
+	}
+	i := k.find(name)
+	if i < 0 {
+		return fmt.Errorf("no key named '%s'", name)
+	}
+	if k.Keys[i].Protected {
+		return fmt.Errorf("key '%s' is already passphrase-protected", name)
+	}
+
+	content, err := os.ReadFile(keyPath(dir, k.Keys[i]))
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	if err := writeKeyFile(dir, &k.Keys[i], content, passphrase); err != nil {
+		return err
+	}
+	return k.save(dir)
+}
+
+// UnprotectKey stores the identity file of a named key in plaintext again
+func UnprotectKey(name, passphrase string) error {
+	k, dir, err := loadKeyring()
+	if err != nil {
+		return err
+	}
+	i := k.find(name)
+	if i < 0 {
+		return fmt.Errorf("no key named '%s'", name)
+	}
+	if !k.Keys[i].Protected {
+		return fmt.Errorf("key '%s' is not passphrase-protected", name)
+	}
+
+	data, err := os.ReadFile(keyPath(dir, k.Keys[i]))
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	content, err := crypto.UnprotectIdentity(data, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := writeKeyFile(dir, &k.Keys[i], content, ""); err != nil {
+		return err
+	}
+	return k.save(dir)
+}
+
+// DeleteKey removes a named key. If it was active, the first remaining key
+// becomes active.
+func DeleteKey(name string) error {
+	k, dir, err := loadKeyring()
+	if err != nil {
+		return err
+	}
+	i := k.find(name)
+	if i < 0 {
+		return fmt.Errorf("no key named '%s'", name)
+	}
+
+	if err := os.Remove(keyPath(dir, k.Keys[i])); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	k.Keys = slices.Delete(k.Keys, i, i+1)
+	if k.Active == name {
+		k.Active = ""
+		if len(k.Keys) > 0 {
+			k.Active = k.Keys[0].Name
+		}
+	}
+	return k.save(dir)
+}
+
+// RegisterProject remembers a project directory, so that deleting a key can
+// warn about projects that still encrypt to it
+func RegisterProject(rootDir string) error {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(Projects(), rootDir) {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, projectsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(rootDir + "\n")
+	return err
+}
+
+// Projects returns the registered project directories
+func Projects() []string {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, projectsFile))
+	if err != nil {
+		return nil
+	}
+
+	var projects []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			projects = append(projects, line)
+		}
+	}
+	return projects
+}
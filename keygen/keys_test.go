@@ -0,0 +1,185 @@
+package keygen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hades/podx/crypto"
+)
+
+func TestLegacyMigration(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugins bool
+	}{
+		{"secret keys only", false},
+		{"with plugin identities", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := testHome(t)
+			dir := filepath.Join(home, ".config", "podx")
+			if err := os.MkdirAll(filepath.Join(dir, ageRecipientsDir), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			first, _, _ := crypto.GenerateAgeKey()
+			second, secondPub, _ := crypto.GenerateAgeKey()
+			legacy := "# created: 2024-01-01\n" + first + "\n" + second + "\n" + first + "\n"
+			if tt.plugins {
+				legacy += "AGE-PLUGIN-YUBIKEY-1QQQQQQ\n"
+			}
+			if err := os.WriteFile(filepath.Join(dir, ageKeysFile), []byte(legacy), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, ageRecipientsDir, "default.txt"), []byte(secondPub+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			k, _, err := loadKeyring()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(k.Keys) != 2 {
+				t.Fatalf("migrated %d keys, want 2", len(k.Keys))
+			}
+			if i := k.find(k.Active); i < 0 || k.Keys[i].PublicKey != secondPub {
+				t.Errorf("active key %q is not the legacy default", k.Active)
+			}
+			for _, info := range k.Keys {
+				data, err := os.ReadFile(keyPath(dir, info))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(data), "AGE-SECRET-KEY-") {
+					t.Errorf("key file of %s holds no key", info.Name)
+				}
+			}
+
+			// No plaintext copy of the secret keys is left behind
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				if strings.HasPrefix(e.Name(), ageKeysFile+".") {
+					t.Errorf("leftover %s", e.Name())
+				}
+			}
+			data, err := os.ReadFile(filepath.Join(dir, ageKeysFile))
+			switch {
+			case tt.plugins && string(data) != "AGE-PLUGIN-YUBIKEY-1QQQQQQ\n":
+				t.Errorf("age-keys.txt = %q, want only the plugin identity", data)
+			case !tt.plugins && !os.IsNotExist(err):
+				t.Errorf("age-keys.txt still exists: %q", data)
+			}
+
+			// Decryption still works with the migrated keys
+			ids, err := LoadAgeIdentity()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Count(ids, "AGE-SECRET-KEY-") != 2 {
+				t.Errorf("identities after migration:\n%s", ids)
+			}
+		})
+	}
+}
+
+func TestRegisterProject(t *testing.T) {
+	testHome(t)
+
+	for _, dir := range []string{"/srv/a", "/srv/b", "/srv/a"} {
+		if err := RegisterProject(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(Projects(), ","); got != "/srv/a,/srv/b" {
+		t.Errorf("Projects() = %s", got)
+	}
+}
+
+func TestProtectKey(t *testing.T) {
+	home := testHome(t)
+	dir := filepath.Join(home, ".config", "podx")
+
+	result, err := GenerateAge("work", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainPath := filepath.Join(dir, keysDir, "work.txt")
+	protectedPath := filepath.Join(dir, keysDir, "work.age")
+
+	if err := ProtectKey("work", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(plainPath); !os.IsNotExist(err) {
+		t.Errorf("plaintext key file left behind: %v", err)
+	}
+	data, err := os.ReadFile(protectedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "AGE-SECRET-KEY-") {
+		t.Fatal("protected key file holds the plaintext key")
+	}
+	keys, _, err := ListKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys[0].Protected || keys[0].PublicKey != result.PublicKey {
+		t.Errorf("keys after protect: %+v", keys)
+	}
+
+	if err := ProtectKey("work", "again"); err == nil {
+		t.Error("protecting a protected key should fail")
+	}
+	if err := ProtectKey("missing", "pw"); err == nil {
+		t.Error("protecting an unknown key should fail")
+	}
+
+	// Decryption asks for the passphrase
+	prompts := 0
+	saved := crypto.IdentityPassphrase
+	t.Cleanup(func() { crypto.IdentityPassphrase = saved })
+	crypto.IdentityPassphrase = func(name string) ([]byte, error) {
+		prompts++
+		return []byte("correct horse"), nil
+	}
+	ciphertext, err := crypto.AgeEncrypt([]byte("hunter2"), result.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := LoadAgeIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := crypto.AgeDecrypt(ciphertext, ids); err != nil || string(plaintext) != "hunter2" {
+		t.Fatalf("AgeDecrypt = %q, %v", plaintext, err)
+	}
+	if prompts != 1 {
+		t.Errorf("prompted %d times, want 1", prompts)
+	}
+
+	// A wrong passphrase leaves the protected file alone
+	if err := UnprotectKey("work", "wrong"); err == nil {
+		t.Fatal("unprotect with a wrong passphrase should fail")
+	}
+	if _, err := os.Stat(protectedPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UnprotectKey("work", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(plainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), result.PrivateKey) {
+		t.Error("unprotected key file does not hold the original key")
+	}
+	if _, err := os.Stat(protectedPath); !os.IsNotExist(err) {
+		t.Errorf("protected key file left behind: %v", err)
+	}
+}
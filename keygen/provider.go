@@ -0,0 +1,198 @@
+package keygen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hades/podx/config"
+)
+
+const trustedCommandsFile = "trusted-commands.txt"
+
+// ConfirmCommand asks whether an identity command declared by a project may
+// run. Set by the CLI; without it, untrusted project commands fail.
+var ConfirmCommand func(configPath, command string) bool
+
+// providerSource is a provider together with the config that declared it
+type providerSource struct {
+	dir     string // Directory of the declaring config file
+	project bool   // Declared by a .podx.yaml, so commands need trust
+	config.IdentityProvider
+}
+
+// providerResult is the cached outcome of loading a provider
+type providerResult struct {
+	data string
+	err  error
+}
+
+var (
+	providerCache   = map[providerSource]providerResult{}
+	providerCacheMu sync.Mutex
+)
+
+// ProjectProviders are the identity providers declared in the .podx.yaml
+// in Dir. They are tried before those in the user config.
+type ProjectProviders struct {
+	Dir       string
+	Providers []config.IdentityProvider
+}
+
+// providerSources returns the project providers followed by the user's
+// default identity and providers from config.yaml
+func providerSources(projects []ProjectProviders) ([]providerSource, error) {
+	var sources []providerSource
+	for _, proj := range projects {
+		for _, p := range proj.Providers {
+			src := providerSource{dir: proj.Dir, project: true, IdentityProvider: p}
+			if !slices.Contains(sources, src) {
+				sources = append(sources, src)
+			}
+		}
+	}
+
+	cfg, err := config.LoadUser()
+	if err != nil {
+		return nil, err
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	providers := cfg.Identities
+	if cfg.Identity != "" {
+		providers = append([]config.IdentityProvider{{File: cfg.Identity}}, providers...)
+	}
+	for _, p := range providers {
+		sources = append(sources, providerSource{dir: configDir, IdentityProvider: p})
+	}
+	return sources, nil
+}
+
+// describe names the provider in identity lists and errors
+func (s providerSource) describe() string {
+	switch {
+	case s.Name != "":
+		return "provider '" + s.Name + "'"
+	case s.Command != "":
+		return "command '" + s.Command + "'"
+	default:
+		return s.File
+	}
+}
+
+// load returns the identity data of the provider. Results, failures
+// included, are cached, so a command runs (and asks for trust) at most once
+// per process.
+func (s providerSource) load() (string, error) {
+	providerCacheMu.Lock()
+	defer providerCacheMu.Unlock()
+
+	if r, ok := providerCache[s]; ok {
+		return r.data, r.err
+	}
+
+	var data string
+	var err error
+	switch {
+	case s.Command != "" && s.File != "":
+		err = fmt.Errorf("set either command or file, not both")
+	case s.Command != "":
+		data, err = s.runCommand()
+	case s.File != "":
+		data, err = s.readFile()
+	default:
+		err = fmt.Errorf("command or file is required")
+	}
+	if err != nil {
+		err = fmt.Errorf("identity %s: %w", s.describe(), err)
+	}
+
+	providerCache[s] = providerResult{data, err}
+	return data, err
+}
+
+func (s providerSource) readFile() (string, error) {
+	path, err := config.ExpandPath(s.File, s.dir)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (s providerSource) runCommand() (string, error) {
+	if s.project {
+		if err := trustCommand(s.dir, s.Command); err != nil {
+			return "", err
+		}
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", s.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", s.Command)
+	}
+	// Password managers may prompt on the terminal
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	cmd.Dir = s.dir
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("'%s' failed: %w", s.Command, err)
+	}
+	if !hasIdentity(out.String()) {
+		return "", fmt.Errorf("'%s' printed no age or SSH identity", s.Command)
+	}
+	return out.String(), nil
+}
+
+// trustCommand makes sure the user agreed to run a command declared by the
+// project in dir. A cloned repository must not run commands on its own.
+func trustCommand(dir, command string) error {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return err
+	}
+	trustFile := filepath.Join(configDir, trustedCommandsFile)
+
+	sum := sha256.Sum256([]byte(dir + "\n" + command))
+	id := hex.EncodeToString(sum[:])
+
+	data, _ := os.ReadFile(trustFile)
+	if slices.Contains(strings.Fields(string(data)), id) {
+		return nil
+	}
+
+	configPath := filepath.Join(dir, ".podx.yaml")
+	if ConfirmCommand == nil || !ConfirmCommand(configPath, command) {
+		return fmt.Errorf("command from %s is not trusted (run podx interactively once to approve it)", configPath)
+	}
+
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(trustFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(id + "\n")
+	return err
+}
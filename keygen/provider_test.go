@@ -0,0 +1,149 @@
+package keygen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hades/podx/config"
+	"github.com/hades/podx/crypto"
+)
+
+// confirmCommands answers every trust prompt with approve and counts them
+func confirmCommands(t *testing.T, approve bool) *int {
+	t.Helper()
+	asked := new(int)
+	saved := ConfirmCommand
+	ConfirmCommand = func(configPath, command string) bool {
+		*asked++
+		return approve
+	}
+	t.Cleanup(func() { ConfirmCommand = saved })
+	return asked
+}
+
+func commandProvider(dir, command string) ProjectProviders {
+	return ProjectProviders{Dir: dir, Providers: []config.IdentityProvider{{Command: command}}}
+}
+
+func TestFailingProviderDeferred(t *testing.T) {
+	testHome(t)
+	confirmCommands(t, true)
+	identity, recipient, err := crypto.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PODX_AGE_KEY", identity)
+
+	// Provider caches are per process, so every test uses its own command
+	proj := commandProvider(t.TempDir(), "echo vault is locked >&2; exit 3")
+	ids, err := LoadAgeIdentity(proj)
+	if err != nil {
+		t.Fatalf("a failing provider aborted the load: %v", err)
+	}
+
+	ciphertext, err := crypto.AgeEncrypt([]byte("hunter2"), recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := crypto.AgeDecrypt(ciphertext, ids); err != nil || string(plaintext) != "hunter2" {
+		t.Fatalf("AgeDecrypt = %q, %v", plaintext, err)
+	}
+
+	// Encrypted to someone else: the failing provider might have had the key
+	_, other, err := crypto.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err = crypto.AgeEncrypt([]byte("hunter2"), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = crypto.AgeDecrypt(ciphertext, ids)
+	if err == nil || !strings.Contains(err.Error(), "not loaded") || !strings.Contains(err.Error(), "exit 3") {
+		t.Errorf("error does not name the failing provider: %v", err)
+	}
+}
+
+func TestFailingProviderOnly(t *testing.T) {
+	testHome(t)
+	confirmCommands(t, true)
+
+	proj := commandProvider(t.TempDir(), "exit 4")
+	if _, err := LoadAgeIdentity(proj); err == nil || !strings.Contains(err.Error(), "'exit 4' failed") {
+		t.Errorf("expected the provider error, got %v", err)
+	}
+
+	optional := ProjectProviders{Dir: t.TempDir(), Providers: []config.IdentityProvider{{Command: "exit 5", Optional: true}}}
+	if _, err := LoadAgeIdentity(optional); err == nil || strings.Contains(err.Error(), "exit 5") {
+		t.Errorf("optional provider reported: %v", err)
+	}
+}
+
+func TestTrustCommand(t *testing.T) {
+	home := testHome(t)
+	dir := t.TempDir()
+	command := "true # trust test"
+
+	if err := trustCommand(dir, command); err == nil || !strings.Contains(err.Error(), "not trusted") {
+		t.Fatalf("expected an untrusted error without a prompt, got %v", err)
+	}
+
+	asked := confirmCommands(t, false)
+	if err := trustCommand(dir, command); err == nil || *asked != 1 {
+		t.Fatalf("declined command was trusted: %d prompts, %v", *asked, err)
+	}
+	trustFile := filepath.Join(home, ".config", "podx", trustedCommandsFile)
+	if _, err := os.Stat(trustFile); !os.IsNotExist(err) {
+		t.Errorf("declined command recorded: %v", err)
+	}
+
+	asked = confirmCommands(t, true)
+	if err := trustCommand(dir, command); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(trustFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(dir + "\n" + command))
+	if want := hex.EncodeToString(sum[:]) + "\n"; string(data) != want {
+		t.Errorf("%s = %q, want %q", trustedCommandsFile, data, want)
+	}
+
+	// Approved once, never asked again
+	if err := trustCommand(dir, command); err != nil || *asked != 1 {
+		t.Errorf("trusted command asked again: %d prompts, %v", *asked, err)
+	}
+
+	// Another command, or the same one from another project, needs approval
+	if err := trustCommand(dir, command+" changed"); err != nil || *asked != 2 {
+		t.Errorf("changed command not confirmed: %d prompts, %v", *asked, err)
+	}
+	if err := trustCommand(t.TempDir(), command); err != nil || *asked != 3 {
+		t.Errorf("command in another project not confirmed: %d prompts, %v", *asked, err)
+	}
+}
+
+func TestUntrustedProjectCommandNotRun(t *testing.T) {
+	testHome(t)
+	confirmCommands(t, false)
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+
+	identity, _, err := crypto.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PODX_AGE_KEY", identity)
+
+	if _, err := LoadAgeIdentity(commandProvider(dir, "touch ran")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("untrusted project command ran")
+	}
+}
@@ -3,15 +3,23 @@ package main
 import (
 	"bufio"
 	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/hades/podx/agent"
+	"github.com/hades/podx/config"
 	"github.com/hades/podx/crypto"
+	"github.com/hades/podx/export"
+	"github.com/hades/podx/importer"
 	"github.com/hades/podx/keygen"
 	"github.com/hades/podx/parser"
 	"github.com/hades/podx/project"
@@ -38,6 +46,22 @@ const banner = `
 `
 
 func main() {
+	crypto.SSHPassphrase = func(name string) ([]byte, error) {
+		return []byte(getPassword("", fmt.Sprintf("Enter passphrase for %s: ", name))), nil
+	}
+	crypto.IdentityPassphrase = crypto.SSHPassphrase
+	crypto.AgentIdentity = agent.NewIdentity
+	keygen.ConfirmCommand = func(configPath, command string) bool {
+		// Piped input must not approve commands by accident
+		if !term.IsTerminal(int(syscall.Stdin)) {
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "%s wants to run this command to get your identity:\n  %s\nAllow it? [y/N] ", configPath, command)
+		answer, _ := stdinReader.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
@@ -53,21 +77,49 @@ func main() {
 	case "encrypt-all":
 		handleEncryptAll()
 	case "decrypt-all":
-		handleDecryptAll()
+		handleDecryptAll(os.Args[2:])
 	case "status":
-		handleStatus()
+		handleStatus(os.Args[2:])
+	case "compare":
+		handleCompare(os.Args[2:])
+	case "export":
+		handleExport(os.Args[2:])
+	case "import":
+		handleImport(os.Args[2:])
+	case "render":
+		handleRender(os.Args[2:])
+	case "lint":
+		handleLint(os.Args[2:])
 	case "encrypt":
 		handleEncrypt(os.Args[2:])
 	case "decrypt":
 		handleDecrypt(os.Args[2:])
 	case "env":
 		if len(os.Args) < 3 {
-			fmt.Println("Usage: podx env <encrypt|decrypt> [options]")
+			fmt.Println("Usage: podx env <encrypt|decrypt|example|validate> [options]")
 			os.Exit(1)
 		}
 		handleEnv(os.Args[2], os.Args[3:])
+	case "agent":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: podx agent <start|add|lock|status|stop> [options]")
+			os.Exit(1)
+		}
+		handleAgent(os.Args[2], os.Args[3:])
+	case "config":
+		subcmd := "show"
+		if len(os.Args) > 2 {
+			subcmd = os.Args[2]
+		}
+		handleConfig(subcmd)
 	case "keygen":
 		handleKeygen(os.Args[2:])
+	case "key":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: podx key <list|use|export|import|protect|unprotect|delete> [options]")
+			os.Exit(1)
+		}
+		handleKey(os.Args[2], os.Args[3:])
 	case "update":
 		handleUpdate()
 	case "version", "-v", "--version":
@@ -90,14 +142,22 @@ PROJECT COMMANDS:
   encrypt-all    Encrypt all secrets in project
   decrypt-all    Decrypt all secrets in project
   status         Show project status
+  compare        Compare keys between two encrypted .env files
+  import         Import secrets from sops, dotenv-vault or plaintext files
+  render         Render a template with decrypted secrets
+  lint           Check encrypted .env files for common mistakes
 
 FILE COMMANDS:
   encrypt    Encrypt a single file
   decrypt    Decrypt a single file
-  env        Encrypt/decrypt .env file (format-preserving)
+  env        Encrypt/decrypt, validate .env or generate .env.example
+  export     Decrypt .env in memory and print it in another format
   keygen     Generate Age or GPG key pair
+  key        Manage named age keys (list, use, protect, delete, ...)
+  agent      Keep unlocked keys in memory (start, add, lock, status, stop)
 
 OTHER:
+  config     Show, locate or edit the user config (config.yaml)
   update     Self-update to latest version
   version    Show version info
 
@@ -106,16 +166,27 @@ USAGE:
   podx add-recipient -n "Name" -k KEY    # Add team member
   podx encrypt-all                       # Encrypt all secrets
   podx decrypt-all                       # Decrypt all secrets
-  podx keygen -t age                     # Generate Age key
+  podx status --recursive                # Status of all sub-projects
+  podx compare a.podx b.podx             # Diff key sets
+  podx import secrets.enc.yaml           # Re-key a sops file
+  podx render -t nginx.conf.tmpl -o nginx.conf
+  podx lint --fix                        # Lint project .env files
+  podx keygen -t age -n work             # Generate named Age key
+  podx key use work                      # Switch active key
+  eval "$(podx agent start)"             # Unlock keys once per session
+  podx config                            # Show effective defaults
   podx update                            # Update to latest
   podx encrypt -a aes-gcm -i F -o F.enc  # Encrypt file
-  podx env encrypt -i .env -o .env.podx  # Encrypt .env`)
+  podx env encrypt -i .env -o .env.podx  # Encrypt .env
+  podx env validate .env.podx            # Check against schema
+  podx export -f .env.podx --format json # Print decrypted JSON`)
 }
 
 func handleEncrypt(args []string) {
 	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
-	algo := fs.String("a", "aes-gcm", "Algorithm (aes-gcm or chacha20)")
-	fs.String("algorithm", "aes-gcm", "")
+	algo := fs.String("a", "", "Algorithm (aes-gcm or chacha20, default from config)")
+	fs.StringVar(algo, "algorithm", "", "")
+	kdf := fs.String("kdf", "", "Argon2id profile (default, moderate or sensitive)")
 	input := fs.String("i", "", "Input file")
 	fs.String("input", "", "")
 	output := fs.String("o", "", "Output file")
@@ -133,11 +204,17 @@ func handleEncrypt(args []string) {
 		os.Exit(1)
 	}
 
+	settings := loadSettings()
+	if *algo == "" {
+		*algo = settings.Algorithm
+	}
+	profile := kdfProfile(*kdf, settings)
+
 	// Get password
 	pass := getPassword(*password, "Enter password: ")
 
 	// Derive key
-	key, salt, err := crypto.DeriveKey([]byte(pass), nil)
+	key, salt, err := profile.DeriveKey([]byte(pass), nil)
 	if err != nil {
 		fmt.Println("Error deriving key:", err)
 		os.Exit(1)
@@ -164,10 +241,11 @@ func handleEncrypt(args []string) {
 		os.Exit(1)
 	}
 
-	// Write output: [salt (16 bytes)][algo (1 byte)][ciphertext]
-	algoB := byte(0)
+	// Write output: [salt (16 bytes)][algo (1 byte)][ciphertext]. The high
+	// bits of the algo byte hold the KDF profile, 0 for files before profiles.
+	algoB := profile.ID << 4
 	if *algo == "chacha20" {
-		algoB = 1
+		algoB |= 1
 	}
 
 	out := append(salt, algoB)
@@ -223,12 +301,17 @@ func handleDecrypt(args []string) {
 	ciphertext := data[crypto.SaltSize+1:]
 
 	algo := crypto.AlgoAESGCM
-	if algoB == 1 {
+	if algoB&0x0f == 1 {
 		algo = crypto.AlgoChaCha20
 	}
+	profile, err := crypto.KDFProfileByID(algoB >> 4)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
 
 	// Derive key
-	key, err := crypto.DeriveKeyWithSalt([]byte(pass), salt)
+	key, _, err := profile.DeriveKey([]byte(pass), salt)
 	if err != nil {
 		fmt.Println("Error deriving key:", err)
 		os.Exit(1)
@@ -257,9 +340,20 @@ func handleDecrypt(args []string) {
 }
 
 func handleEnv(subcmd string, args []string) {
+	switch subcmd {
+	case "example":
+		handleEnvExample(args)
+		return
+	case "validate":
+		handleEnvValidate(args)
+		return
+	}
+
 	fs := flag.NewFlagSet("env", flag.ExitOnError)
-	algo := fs.String("a", "aes-gcm", "Algorithm (aes-gcm or chacha20)")
-	fs.String("algorithm", "aes-gcm", "")
+	addIdentityFlags(fs, false)
+	algo := fs.String("a", "", "Algorithm (aes-gcm or chacha20, default from config)")
+	fs.StringVar(algo, "algorithm", "", "")
+	kdf := fs.String("kdf", "", "Argon2id profile (default, moderate or sensitive)")
 	input := fs.String("i", "", "Input .env file")
 	fs.String("input", "", "")
 	output := fs.String("o", "", "Output .env file")
@@ -279,7 +373,11 @@ func handleEnv(subcmd string, args []string) {
 
 	switch subcmd {
 	case "encrypt":
-		handleEnvEncrypt(*input, *output, *algo, *password)
+		settings := loadSettings()
+		if *algo == "" {
+			*algo = settings.Algorithm
+		}
+		handleEnvEncrypt(*input, *output, *algo, kdfProfile(*kdf, settings), *password)
 	case "decrypt":
 		handleEnvDecrypt(*input, *output, *password)
 	default:
@@ -288,7 +386,113 @@ func handleEnv(subcmd string, args []string) {
 	}
 }
 
-func handleEnvEncrypt(input, output, algo, password string) {
+func handleEnvExample(args []string) {
+	fs := flag.NewFlagSet("env example", flag.ExitOnError)
+	output := fs.String("o", ".env.example", "Output file (- for stdout)")
+	fs.StringVar(output, "output", ".env.example", "")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		files = []string{".env" + project.EncryptedExt}
+	}
+
+	cwd, _ := os.Getwd()
+	p, err := project.Load(cwd)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	// Only key names and comments are used, so nothing is decrypted
+	var parsed [][]parser.EnvEntry
+	for _, file := range files {
+		entries, err := parser.ParseEnvFile(file)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		parsed = append(parsed, entries)
+	}
+
+	out := p.EnvExample(files, parsed)
+	if *output == "-" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(*output, out, 0644); err != nil {
+		fmt.Println("Error writing output:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Generated %s from %s\n", *output, strings.Join(files, ", "))
+}
+
+func handleEnvValidate(args []string) {
+	fs := flag.NewFlagSet("env validate", flag.ExitOnError)
+	addIdentityFlags(fs, true)
+	password := fs.String("p", "", "Password (for password-encrypted files)")
+	fs.StringVar(password, "password", "", "")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		files = []string{".env" + project.EncryptedExt}
+	}
+
+	cwd, _ := os.Getwd()
+	p, err := project.Load(cwd)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if len(p.Config.Schema) == 0 {
+		fmt.Println("Error: no schema in .podx.yaml")
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, file := range files {
+		entries, err := parser.ParseEnvFile(file)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		entries, err = decryptEnvEntries(entries, *password)
+		if err != nil {
+			fmt.Printf("Error decrypting %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		vars, err := envVars(file, entries)
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", file, err)
+			os.Exit(1)
+		}
+
+		problems := p.ValidateEnv(vars)
+		if len(problems) == 0 {
+			fmt.Printf("✓ %s matches schema (%d keys)\n", file, len(p.Config.Schema))
+			continue
+		}
+
+		failed = true
+		fmt.Printf("❌ %s: %d problem(s)\n", file, len(problems))
+		for _, problem := range problems {
+			fmt.Printf("   - %s\n", problem)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func handleEnvEncrypt(input, output, algo string, profile crypto.KDFProfile, password string) {
 	pass := getPassword(password, "Enter password: ")
 
 	// Parse .env
@@ -299,7 +503,7 @@ func handleEnvEncrypt(input, output, algo, password string) {
 	}
 
 	// Derive key
-	key, salt, err := crypto.DeriveKey([]byte(pass), nil)
+	key, salt, err := profile.DeriveKey([]byte(pass), nil)
 	if err != nil {
 		fmt.Println("Error deriving key:", err)
 		os.Exit(1)
@@ -317,7 +521,11 @@ func handleEnvEncrypt(input, output, algo, password string) {
 		IsComment: true,
 		Comment:   fmt.Sprintf("# IRONVAULT_SALT=%s", saltB64),
 	}
-	entries = append([]parser.EnvEntry{saltEntry}, entries...)
+	header := []parser.EnvEntry{saltEntry}
+	if profile.ID != 0 {
+		header = append(header, parser.EnvEntry{IsComment: true, Comment: kdfComment + profile.Name})
+	}
+	entries = append(header, entries...)
 
 	// Write output
 	if err := parser.WriteEnvFile(output, entries); err != nil {
@@ -338,9 +546,10 @@ func handleEnvDecrypt(input, output, password string) {
 		os.Exit(1)
 	}
 
-	// Extract salt from comment
+	// Extract salt and KDF profile from comments
 	var salt []byte
 	var cleanEntries []parser.EnvEntry
+	profile := crypto.KDFProfiles[0]
 
 	for _, entry := range entries {
 		if entry.IsComment && strings.HasPrefix(entry.Comment, "# IRONVAULT_SALT=") {
@@ -350,6 +559,11 @@ func handleEnvDecrypt(input, output, password string) {
 				fmt.Println("Error decoding salt:", err)
 				os.Exit(1)
 			}
+		} else if entry.IsComment && strings.HasPrefix(entry.Comment, kdfComment) {
+			if profile, err = crypto.ParseKDFProfile(strings.TrimPrefix(entry.Comment, kdfComment)); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
 		} else {
 			cleanEntries = append(cleanEntries, entry)
 		}
@@ -361,7 +575,7 @@ func handleEnvDecrypt(input, output, password string) {
 	}
 
 	// Derive key
-	key, err := crypto.DeriveKeyWithSalt([]byte(pass), salt)
+	key, _, err := profile.DeriveKey([]byte(pass), salt)
 	if err != nil {
 		fmt.Println("Error deriving key:", err)
 		os.Exit(1)
@@ -382,17 +596,22 @@ func handleEnvDecrypt(input, output, password string) {
 	fmt.Printf("✓ Decrypted .env: %s → %s\n", input, output)
 }
 
+// stdinReader dipakai bersama supaya beberapa prompt bisa membaca piped input
+// baris per baris
+var stdinReader = bufio.NewReader(os.Stdin)
+
 func getPassword(provided, prompt string) string {
 	if provided != "" {
 		return provided
 	}
 
-	fmt.Print(prompt)
+	// Prompt ke stderr supaya stdout tetap bersih untuk pipeline (export, render)
+	fmt.Fprint(os.Stderr, prompt)
 
 	// Coba baca dari terminal
 	if term.IsTerminal(int(syscall.Stdin)) {
 		password, err := term.ReadPassword(int(syscall.Stdin))
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
 		if err != nil {
 			fmt.Println("Error reading password:", err)
 			os.Exit(1)
@@ -401,8 +620,7 @@ func getPassword(provided, prompt string) string {
 	}
 
 	// Fallback untuk non-terminal (piped input)
-	reader := bufio.NewReader(os.Stdin)
-	password, err := reader.ReadString('\n')
+	password, err := stdinReader.ReadString('\n')
 	if err != nil && err != io.EOF {
 		fmt.Println("Error reading password:", err)
 		os.Exit(1)
@@ -412,27 +630,46 @@ func getPassword(provided, prompt string) string {
 
 func handleKeygen(args []string) {
 	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
-	keyType := fs.String("t", "age", "Key type (age or gpg)")
+	keyType := fs.String("t", "age", "Key type (age, age-pq or gpg)")
 	fs.String("type", "age", "")
-	name := fs.String("n", "", "Name for GPG key")
-	fs.String("name", "", "")
+	name := fs.String("n", "", "Key name (age) or user name (gpg)")
+	fs.StringVar(name, "name", "", "")
 	email := fs.String("e", "", "Email for GPG key")
 	fs.String("email", "", "")
+	comment := fs.String("comment", "", "Comment stored with an age key")
+	protect := fs.Bool("protect", false, "Encrypt the age key file with a passphrase")
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
 
+	passphrase := ""
+	if *protect {
+		if *keyType == "gpg" {
+			fmt.Println("Error: --protect is for age keys (gpg protects its own keys)")
+			os.Exit(1)
+		}
+		passphrase = newPassphrase()
+	}
+
 	switch *keyType {
 	case "age":
-		result, err := keygen.GenerateAge()
+		result, err := keygen.GenerateAge(*name, *comment, passphrase)
 		if err != nil {
 			fmt.Println("Error generating Age key:", err)
 			os.Exit(1)
 		}
 		keygen.PrintKeygenResult(result)
 
+	case "age-pq":
+		result, err := keygen.GenerateAgePQ(*name, *comment, passphrase)
+		if err != nil {
+			fmt.Println("Error generating Age post-quantum key:", err)
+			os.Exit(1)
+		}
+		keygen.PrintKeygenResult(result)
+
 	case "gpg":
 		if *name == "" || *email == "" {
 			fmt.Println("Error: name (-n) and email (-e) are required for GPG key generation")
@@ -446,9 +683,287 @@ func handleKeygen(args []string) {
 		keygen.PrintKeygenResult(result)
 
 	default:
-		fmt.Printf("Unknown key type: %s (supported: age, gpg)\n", *keyType)
+		fmt.Printf("Unknown key type: %s (supported: age, age-pq, gpg)\n", *keyType)
+		os.Exit(1)
+	}
+}
+
+func handleKey(subcmd string, args []string) {
+	switch subcmd {
+	case "list", "ls":
+		keys, active, err := keygen.ListKeys()
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if len(keys) == 0 {
+			fmt.Println("No keys yet. Generate one with: podx keygen -t age")
+			return
+		}
+		for _, k := range keys {
+			marker := " "
+			if k.Name == active {
+				marker = "*"
+			}
+			line := fmt.Sprintf("%s %-12s %-7s %s  %s", marker, k.Name, k.Type, k.Fingerprint, k.Created.Format("2006-01-02"))
+			if k.Protected {
+				line += "  🔒"
+			}
+			if k.Comment != "" {
+				line += "  # " + k.Comment
+			}
+			fmt.Println(line)
+		}
+
+	case "use":
+		if len(args) != 1 {
+			fmt.Println("Usage: podx key use NAME")
+			os.Exit(1)
+		}
+		if err := keygen.UseKey(args[0]); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Active key is now '%s'\n", args[0])
+
+	case "export":
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		pub, err := keygen.ExportKey(name)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println(pub)
+
+	case "import":
+		fs := flag.NewFlagSet("key import", flag.ExitOnError)
+		name := fs.String("n", "", "Key name (suffixed -2, -3... for several keys)")
+		fs.StringVar(name, "name", "", "")
+		comment := fs.String("comment", "", "Comment stored with the key")
+		files, err := parseInterspersed(fs, args)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if len(files) != 1 {
+			fmt.Println("Usage: podx key import FILE [-n NAME] [--comment TEXT]")
+			os.Exit(1)
+		}
+		keys, err := keygen.ImportKeys(files[0], *name, *comment)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		for _, k := range keys {
+			fmt.Printf("✓ Imported '%s' (%s)\n", k.Name, k.Fingerprint)
+		}
+
+	case "protect":
+		if len(args) != 1 {
+			fmt.Println("Usage: podx key protect NAME")
+			os.Exit(1)
+		}
+		if err := keygen.ProtectKey(args[0], newPassphrase()); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Key '%s' is now passphrase-protected\n", args[0])
+
+	case "unprotect":
+		if len(args) != 1 {
+			fmt.Println("Usage: podx key unprotect NAME")
+			os.Exit(1)
+		}
+		pass := getPassword("", fmt.Sprintf("Enter passphrase for key '%s': ", args[0]))
+		if err := keygen.UnprotectKey(args[0], pass); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Key '%s' is stored without a passphrase\n", args[0])
+
+	case "delete", "rm":
+		fs := flag.NewFlagSet("key delete", flag.ExitOnError)
+		force := fs.Bool("f", false, "Delete without confirmation")
+		fs.BoolVar(force, "force", false, "")
+		names, err := parseInterspersed(fs, args)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if len(names) != 1 {
+			fmt.Println("Usage: podx key delete NAME [--force]")
+			os.Exit(1)
+		}
+		handleKeyDelete(names[0], *force)
+
+	default:
+		fmt.Printf("Unknown key subcommand: %s\n", subcmd)
+		os.Exit(1)
+	}
+}
+
+func handleAgent(subcmd string, args []string) {
+	fs := flag.NewFlagSet("agent "+subcmd, flag.ExitOnError)
+	socket := fs.String("socket", "", "Agent socket (default: $PODX_AGENT_SOCK or a per-user runtime dir)")
+	ttl := fs.Duration("ttl", time.Hour, "Drop keys after being idle this long (0 = never)")
+	foreground := fs.Bool("foreground", false, "Run in the foreground instead of detaching")
+	if err := fs.Parse(args); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if *socket == "" {
+		*socket = os.Getenv(agent.SocketEnv)
+	}
+	if *socket == "" {
+		*socket = agent.DefaultSocket()
+	}
+
+	switch subcmd {
+	case "start":
+		if agent.Running(*socket) {
+			fmt.Fprintf(os.Stderr, "Error: an agent is already running on %s\n", *socket)
+			os.Exit(1)
+		}
+		identities := unlockedIdentities()
+		if *foreground {
+			fmt.Fprintf(os.Stderr, "✓ podx agent listening on %s\n", *socket)
+			if err := agent.Serve(*socket, []byte(identities), *ttl); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if err := agent.Spawn([]string{"agent", "serve", "--socket", *socket, "--ttl", ttl.String()}, identities); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		for i := 0; i < 50 && !agent.Running(*socket); i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		status, err := agent.GetStatus(*socket)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: agent did not start:", err)
+			os.Exit(1)
+		}
+		// Shell syntax on stdout for eval "$(podx agent start)"
+		fmt.Printf("%s=%s; export %s;\n", agent.SocketEnv, *socket, agent.SocketEnv)
+		fmt.Fprintf(os.Stderr, "✓ podx agent started (pid %d, %d identities)\n", status.PID, status.Identities)
+
+	case "serve":
+		// Started by 'podx agent start' with the unlocked identities on stdin
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			os.Exit(1)
+		}
+		if err := agent.Serve(*socket, data, *ttl); err != nil {
+			os.Exit(1)
+		}
+
+	case "add":
+		status, err := agent.Add(*socket, unlockedIdentities())
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Agent now holds %d identities\n", status.Identities)
+
+	case "lock":
+		if err := agent.Lock(*socket); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("🔒 Agent locked, keys dropped (unlock again with: podx agent add)")
+
+	case "status":
+		status, err := agent.GetStatus(*socket)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		state := fmt.Sprintf("%d identities", status.Identities)
+		if status.Locked {
+			state = "locked"
+		}
+		fmt.Printf("podx agent on %s (pid %d): %s\n", *socket, status.PID, state)
+		if status.TTL > 0 && !status.Locked {
+			left := time.Until(status.IdleSince.Add(status.TTL)).Round(time.Second)
+			fmt.Printf("Keys are dropped after %s idle (%s left)\n", status.TTL, left)
+		}
+
+	case "stop":
+		if err := agent.Stop(*socket); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("✓ Agent stopped")
+
+	default:
+		fmt.Printf("Unknown agent subcommand: %s\n", subcmd)
+		os.Exit(1)
+	}
+}
+
+// unlockedIdentities loads the user's identities and unlocks the
+// passphrase-protected ones, prompting here, to hand them to the agent
+func unlockedIdentities() string {
+	// The agent must not be handed a reference to itself
+	os.Unsetenv(agent.SocketEnv)
+
+	identity, err := keygen.LoadAgeIdentity()
+	if err == nil {
+		identity, err = crypto.UnlockIdentities(identity)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	return identity
+}
+
+// newPassphrase asks for a new key passphrase twice
+func newPassphrase() string {
+	pass := getPassword("", "Enter new passphrase: ")
+	if pass == "" {
+		fmt.Println("Error: passphrase must not be empty")
+		os.Exit(1)
+	}
+	if getPassword("", "Confirm passphrase: ") != pass {
+		fmt.Println("Error: passphrases do not match")
+		os.Exit(1)
+	}
+	return pass
+}
+
+func handleKeyDelete(name string, force bool) {
+	pub, err := keygen.ExportKey(name)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	// Files in these projects become undecryptable for us without the key
+	if projects := project.ProjectsUsingKey(pub); len(projects) > 0 && !force {
+		fmt.Printf("⚠ Key '%s' is a recipient in:\n", name)
+		for _, dir := range projects {
+			fmt.Println("  " + dir)
+		}
+		fmt.Fprintf(os.Stderr, "Type the key name to delete it anyway: ")
+		answer, _ := stdinReader.ReadString('\n')
+		if strings.TrimSpace(answer) != name {
+			fmt.Println("Aborted")
+			os.Exit(1)
+		}
+	}
+
+	if err := keygen.DeleteKey(name); err != nil {
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
+	fmt.Printf("✓ Deleted key '%s'\n", name)
 }
 
 // Project commands
@@ -473,7 +988,7 @@ func handleAddRecipient(args []string) {
 	fs := flag.NewFlagSet("add-recipient", flag.ExitOnError)
 	name := fs.String("n", "", "Recipient name")
 	fs.String("name", "", "")
-	key := fs.String("k", "", "Recipient Age public key")
+	key := fs.String("k", "", "Recipient public key (age1..., ssh-ed25519, ssh-rsa) or a .pub file")
 	fs.String("key", "", "")
 
 	if err := fs.Parse(args); err != nil {
@@ -487,6 +1002,16 @@ func handleAddRecipient(args []string) {
 		os.Exit(1)
 	}
 
+	// SSH public keys are usually passed as their .pub file
+	if strings.HasSuffix(*key, ".pub") {
+		data, err := os.ReadFile(*key)
+		if err != nil {
+			fmt.Println("Error reading public key:", err)
+			os.Exit(1)
+		}
+		*key = strings.TrimSpace(string(data))
+	}
+
 	cwd, _ := os.Getwd()
 	p, err := project.Load(cwd)
 	if err != nil {
@@ -523,7 +1048,14 @@ func handleEncryptAll() {
 	}
 }
 
-func handleDecryptAll() {
+func handleDecryptAll(args []string) {
+	fs := flag.NewFlagSet("decrypt-all", flag.ExitOnError)
+	addIdentityFlags(fs, true)
+	if err := fs.Parse(args); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
 	cwd, _ := os.Getwd()
 	p, err := project.Load(cwd)
 	if err != nil {
@@ -544,7 +1076,16 @@ func handleDecryptAll() {
 	}
 }
 
-func handleStatus() {
+func handleStatus(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	recursive := fs.Bool("r", false, "Include nested sub-projects")
+	fs.BoolVar(recursive, "recursive", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
 	cwd, _ := os.Getwd()
 	p, err := project.Load(cwd)
 	if err != nil {
@@ -552,15 +1093,700 @@ func handleStatus() {
 		os.Exit(1)
 	}
 
-	fmt.Println(p.Status())
+	if !*recursive {
+		fmt.Println(p.Status())
+		return
+	}
+
+	projects, err := project.Discover(p)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	for _, sub := range projects {
+		fmt.Println(sub.Status())
+	}
 }
 
-func printVersion() {
-	fmt.Printf("PODX %s\n", Version)
-	fmt.Printf("Build time: %s\n", BuildTime)
-	fmt.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
-	
-	// Check for updates in background
+func handleCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	addIdentityFlags(fs, true)
+	values := fs.Bool("values", false, "Decrypt and compare values (shows hashes only)")
+	password := fs.String("p", "", "Password (for password-encrypted files)")
+	fs.StringVar(password, "password", "", "")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if len(files) != 2 {
+		fmt.Println("Error: exactly two files are required")
+		fmt.Println("Usage: podx compare [--values] a.podx b.podx")
+		os.Exit(1)
+	}
+
+	a, err := parser.ParseEnvFile(files[0])
+	if err != nil {
+		fmt.Printf("Error parsing %s: %v\n", files[0], err)
+		os.Exit(1)
+	}
+	b, err := parser.ParseEnvFile(files[1])
+	if err != nil {
+		fmt.Printf("Error parsing %s: %v\n", files[1], err)
+		os.Exit(1)
+	}
+
+	var diff *parser.EnvDiff
+	var hasher *parser.ValueHasher
+	if *values {
+		if a, err = decryptEnvEntries(a, *password); err != nil {
+			fmt.Printf("Error decrypting %s: %v\n", files[0], err)
+			os.Exit(1)
+		}
+		if b, err = decryptEnvEntries(b, *password); err != nil {
+			fmt.Printf("Error decrypting %s: %v\n", files[1], err)
+			os.Exit(1)
+		}
+		if hasher, err = parser.NewValueHasher(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		diff = parser.CompareEnvValues(a, b)
+	} else {
+		diff = parser.CompareEnvKeys(a, b)
+	}
+
+	fmt.Printf("🔍 Comparing %s ↔ %s\n\n", files[0], files[1])
+
+	printKeyList := func(title string, keys []string) {
+		fmt.Printf("%s (%d)\n", title, len(keys))
+		for _, k := range keys {
+			fmt.Printf("   - %s\n", k)
+		}
+	}
+	printKeyList(fmt.Sprintf("❌ Only in %s", files[0]), diff.OnlyInA)
+	printKeyList(fmt.Sprintf("❌ Only in %s", files[1]), diff.OnlyInB)
+	fmt.Printf("✓ Common keys: %d\n", len(diff.Common))
+
+	if *values {
+		fmt.Printf("⚠️  Different values (%d)\n", len(diff.Changed))
+		valuesA, valuesB := parser.EnvValues(a), parser.EnvValues(b)
+		for _, k := range diff.Changed {
+			fmt.Printf("   - %s (%s ≠ %s)\n", k, hasher.Hash(valuesA[k]), hasher.Hash(valuesB[k]))
+		}
+	}
+
+	if diff.HasDifferences() {
+		os.Exit(1)
+	}
+}
+
+func handleExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	addIdentityFlags(fs, true)
+	file := fs.String("f", "", "Encrypted .env file")
+	fs.StringVar(file, "file", "", "")
+	format := fs.String("format", "", "Output format ("+strings.Join(export.Formats, ", ")+", default from config or shell)")
+	name := fs.String("name", "", "Secret name (k8s-secret, default: derived from file name)")
+	namespace := fs.String("namespace", "", "Secret namespace (k8s-secret)")
+	password := fs.String("p", "", "Password (for password-encrypted files)")
+	fs.StringVar(password, "password", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "Error: file (-f) is required")
+		fmt.Fprintln(os.Stderr, "Usage: podx export -f .env.podx --format shell|json|yaml|docker-env|systemd|github-output|k8s-secret|tfvars")
+		os.Exit(1)
+	}
+
+	entries, err := parser.ParseEnvFile(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error parsing .env:", err)
+		os.Exit(1)
+	}
+
+	entries, err = decryptEnvEntries(entries, *password)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error decrypting:", err)
+		os.Exit(1)
+	}
+	vars, err := envVars(*file, entries)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	if *format == "" {
+		*format = loadSettings().Format
+	}
+
+	opts := export.Options{Name: *name, Namespace: *namespace}
+	if opts.Name == "" {
+		opts.Name = export.SecretName(*file)
+	}
+
+	out, err := export.Render(*format, vars, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	os.Stdout.Write(out)
+}
+
+func handleLint(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "Apply safe fixes (remove overridden duplicates, comment out invalid lines)")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	// Without arguments, lint the encrypted .env files of the project
+	if len(files) == 0 {
+		cwd, _ := os.Getwd()
+		p, err := project.Load(cwd)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		rels, err := p.EncryptedEnvFiles()
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		for _, rel := range rels {
+			path, _ := filepath.Rel(cwd, filepath.Join(p.RootDir, filepath.FromSlash(rel)))
+			files = append(files, path)
+		}
+		if len(files) == 0 {
+			fmt.Println("No encrypted .env files found")
+			return
+		}
+	}
+
+	problems := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Println("Error reading input:", err)
+			os.Exit(1)
+		}
+
+		if *fix {
+			fixed, n := parser.FixEnv(data)
+			if n > 0 {
+				// Keep the mode, a plaintext .env is often 0600
+				info, err := os.Stat(file)
+				if err != nil {
+					fmt.Println("Error reading input:", err)
+					os.Exit(1)
+				}
+				if err := writeFileAtomic(file, fixed, info.Mode().Perm()); err != nil {
+					fmt.Println("Error writing output:", err)
+					os.Exit(1)
+				}
+				fmt.Printf("✓ %s: fixed %d issue(s)\n", file, n)
+				data = fixed
+			}
+		}
+
+		// Files named .podx should not contain plaintext values
+		for _, d := range parser.LintEnv(data, strings.HasSuffix(file, project.EncryptedExt)) {
+			fmt.Printf("%s:%s\n", file, d)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		fmt.Printf("\n%d problem(s) found\n", problems)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ %d file(s) checked, no problems\n", len(files))
+}
+
+func handleRender(args []string) {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	addIdentityFlags(fs, true)
+	tmplFile := fs.String("t", "", "Template file (Go text/template)")
+	fs.StringVar(tmplFile, "template", "", "")
+	output := fs.String("o", "", "Output file (default: stdout)")
+	fs.StringVar(output, "output", "", "")
+	password := fs.String("p", "", "Password (for password-encrypted files)")
+	fs.StringVar(password, "password", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	if *tmplFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: template (-t) is required")
+		fmt.Fprintln(os.Stderr, "Usage: podx render -t template.tmpl [-o output]")
+		os.Exit(1)
+	}
+
+	text, err := os.ReadFile(*tmplFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading template:", err)
+		os.Exit(1)
+	}
+
+	// Each encrypted file is decrypted once, however often it is referenced
+	cache := map[string][]parser.EnvVar{}
+	lookup := func(file, key string) (string, error) {
+		vars, ok := cache[file]
+		if !ok {
+			entries, err := parser.ParseEnvFile(file)
+			if err != nil {
+				return "", err
+			}
+			entries, err = decryptEnvEntries(entries, *password)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", file, err)
+			}
+			vars, err = envVars(file, entries)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", file, err)
+			}
+			cache[file] = vars
+		}
+
+		for _, v := range vars {
+			if v.Key == key {
+				return v.Value, nil
+			}
+		}
+		return "", fmt.Errorf("%s: key '%s' not found", file, key)
+	}
+
+	out, err := export.Template(filepath.Base(*tmplFile), text, lookup)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(out)
+		return
+	}
+
+	if err := writeFileAtomic(*output, out, 0600); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing output:", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "✓ Rendered %s → %s\n", *tmplFile, *output)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func handleConfig(subcmd string) {
+	path, err := config.Path()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	switch subcmd {
+	case "show":
+		settings := loadSettings()
+		updateCheck := "true"
+		if !*settings.UpdateCheck {
+			updateCheck = "false"
+		}
+		rows := []struct{ name, value string }{
+			{"identity", settings.Identity},
+			{"algorithm", settings.Algorithm},
+			{"kdf", settings.KDF},
+			{"editor", settings.Editor},
+			{"update_check", updateCheck},
+			{"format", settings.Format},
+		}
+		fmt.Printf("Config file: %s\n\n", path)
+		for _, row := range rows {
+			value := row.value
+			if value == "" {
+				value = "-"
+			}
+			fmt.Printf("  %-13s %-20s (%s)\n", row.name, value, settings.Sources[row.name])
+		}
+
+	case "path":
+		fmt.Println(path)
+
+	case "edit":
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		// The editor setting may carry arguments, e.g. "code --wait"
+		editor := strings.Fields(loadSettings().Editor)
+		if len(editor) == 0 {
+			fmt.Println("Error: no editor configured")
+			os.Exit(1)
+		}
+		cmd := exec.Command(editor[0], append(editor[1:], path)...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Println("Error running editor:", err)
+			os.Exit(1)
+		}
+		if _, err := config.LoadUser(); err != nil {
+			fmt.Println("⚠ ", err)
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Printf("Unknown config subcommand: %s (show, path or edit)\n", subcmd)
+		os.Exit(1)
+	}
+}
+
+// kdfComment records a non-default KDF profile in password-encrypted .env
+// files, next to the IRONVAULT_SALT comment
+const kdfComment = "# IRONVAULT_KDF="
+
+// loadSettings resolves the defaults that flags didn't set: environment,
+// then the project's .podx.yaml, then the user's config.yaml
+func loadSettings() config.Resolved {
+	layers := []config.Layer{{Name: "environment", Settings: config.FromEnv()}}
+	if cwd, err := os.Getwd(); err == nil {
+		if p, err := project.Load(cwd); err == nil {
+			layers = append(layers, p.SettingsLayers()...)
+		}
+	}
+
+	var userLayer config.Layer
+	user, err := config.LoadUser()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	} else {
+		path, _ := config.Path()
+		userLayer = config.Layer{Name: path, Settings: user.Settings}
+		layers = append(layers, userLayer)
+	}
+	settings := config.Resolve(layers...)
+	settings.KDFFloor(userLayer)
+	return settings
+}
+
+// kdfProfile returns the --kdf profile, or the configured one
+func kdfProfile(flagValue string, settings config.Resolved) crypto.KDFProfile {
+	name := flagValue
+	if name == "" {
+		name = settings.KDF
+	}
+	profile, err := crypto.ParseKDFProfile(name)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	return profile
+}
+
+// loadAgeIdentity loads the user's identities together with the identity
+// providers of the project in the working directory, if any
+func loadAgeIdentity() (string, error) {
+	var providers []keygen.ProjectProviders
+	if cwd, err := os.Getwd(); err == nil {
+		if p, err := project.Load(cwd); err == nil {
+			providers = p.IdentityProviders()
+		}
+	}
+	return keygen.LoadAgeIdentity(providers...)
+}
+
+// decryptEnvEntries decrypts age values with the user's identity and
+// password-encrypted values (podx env encrypt) with the given password.
+func decryptEnvEntries(entries []parser.EnvEntry, password string) ([]parser.EnvEntry, error) {
+	var salt []byte
+	var cleanEntries []parser.EnvEntry
+	hasAge, hasPassword := false, false
+	profile := crypto.KDFProfiles[0]
+
+	for _, entry := range entries {
+		if entry.IsComment && strings.HasPrefix(entry.Comment, "# IRONVAULT_SALT=") {
+			s, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(entry.Comment, "# IRONVAULT_SALT="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid salt: %w", err)
+			}
+			salt = s
+			continue
+		}
+		if entry.IsComment && strings.HasPrefix(entry.Comment, kdfComment) {
+			var err error
+			if profile, err = crypto.ParseKDFProfile(strings.TrimPrefix(entry.Comment, kdfComment)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if entry.Encrypted {
+			if entry.Algorithm == "age" {
+				hasAge = true
+			} else {
+				hasPassword = true
+			}
+		}
+		cleanEntries = append(cleanEntries, entry)
+	}
+
+	if hasAge {
+		identity, err := loadAgeIdentity()
+		if err != nil {
+			return nil, err
+		}
+		if err := parser.DecryptAgeValues(cleanEntries, identity); err != nil {
+			return nil, err
+		}
+	}
+
+	if hasPassword {
+		if salt == nil {
+			return nil, fmt.Errorf("no salt found in encrypted file")
+		}
+		pass := getPassword(password, "Enter password: ")
+		key, _, err := profile.DeriveKey([]byte(pass), salt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		if err := parser.DecryptEnvValues(cleanEntries, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return cleanEntries, nil
+}
+
+// envVars returns the decrypted variables of file with podx:// references
+// resolved, and ${VAR} references expanded when the project owning the file
+// enables interpolation
+func envVars(file string, entries []parser.EnvEntry) ([]parser.EnvVar, error) {
+	vars := parser.EnvVars(entries)
+
+	p, err := project.Load(filepath.Dir(mustAbs(file)))
+	if err != nil {
+		return vars, nil
+	}
+
+	if vars, err = p.ResolveRefs(vars); err != nil {
+		return nil, err
+	}
+	if p.Config.Interpolation == nil || !p.Config.Interpolation.Enabled {
+		return vars, nil
+	}
+	return parser.Interpolate(vars, p.Config.Interpolation.Strict, os.LookupEnv)
+}
+
+// identityFlag adds each -i/--identity value to the identities tried when
+// decrypting
+type identityFlag struct{}
+
+func (identityFlag) String() string { return "" }
+
+func (identityFlag) Set(path string) error {
+	keygen.AddIdentityFile(path)
+	return nil
+}
+
+// addIdentityFlags registers --identity, and -i when the command does not
+// already use it for its input file
+func addIdentityFlags(fs *flag.FlagSet, short bool) {
+	if short {
+		fs.Var(identityFlag{}, "i", "Identity file to decrypt with (repeatable)")
+	}
+	fs.Var(identityFlag{}, "identity", "Identity file to decrypt with (repeatable)")
+}
+
+// parseInterspersed parses flags that may appear before or after positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func handleImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	addIdentityFlags(fs, true)
+	from := fs.String("from", "auto", "Source (auto, sops, dotenv-vault, plain)")
+	output := fs.String("o", "", "Destination path of the decrypted file (single input only)")
+	fs.StringVar(output, "output", "", "")
+	format := fs.String("format", "", "Format to record in .podx.yaml (dotenv, yaml, json, ini, pem, binary)")
+	dotenvKey := fs.String("dotenv-key", os.Getenv("DOTENV_KEY"), "DOTENV_KEY for .env.vault files")
+	skipMAC := fs.Bool("skip-mac", false, "Import sops files even if their MAC doesn't match (modified outside sops)")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if len(files) == 0 {
+		fmt.Println("Error: at least one file is required")
+		fmt.Println("Usage: podx import [--from sops|dotenv-vault|plain] [-o PATH] FILE...")
+		os.Exit(1)
+	}
+	if *output != "" && len(files) > 1 {
+		fmt.Println("Error: -o can only be used with a single input file")
+		os.Exit(1)
+	}
+
+	cwd, _ := os.Getwd()
+	p, err := project.Load(cwd)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Println("Error reading input:", err)
+			os.Exit(1)
+		}
+
+		source := *from
+		if source == "auto" {
+			switch {
+			case importer.IsSops(data):
+				source = "sops"
+			case importer.IsDotenvVault(data):
+				source = "dotenv-vault"
+			default:
+				source = "plain"
+			}
+		}
+
+		dest := file
+		var plaintext []byte
+		switch source {
+		case "sops":
+			identity, err := loadAgeIdentity()
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			plaintext, err = importer.DecryptSops(data, parser.DetectFormat(file, data), identity, *skipMAC)
+			if err != nil {
+				fmt.Printf("Error decrypting %s: %v\n", file, err)
+				if errors.Is(err, importer.ErrSopsMAC) {
+					fmt.Println("Check the file's history; use --skip-mac to import it anyway")
+				}
+				os.Exit(1)
+			}
+			dest = importer.SopsPlainPath(file)
+		case "dotenv-vault":
+			if *dotenvKey == "" {
+				fmt.Println("Error: --dotenv-key (or DOTENV_KEY) is required for .env.vault files")
+				os.Exit(1)
+			}
+			environment, decrypted, err := importer.DecryptDotenvVault(data, *dotenvKey)
+			if err != nil {
+				fmt.Printf("Error decrypting %s: %v\n", file, err)
+				os.Exit(1)
+			}
+			plaintext = decrypted
+			dest = filepath.Join(filepath.Dir(file), ".env."+environment)
+			if environment == "development" {
+				dest = filepath.Join(filepath.Dir(file), ".env")
+			}
+		case "plain":
+			plaintext = data
+		default:
+			fmt.Printf("Unknown source: %s (supported: auto, sops, dotenv-vault, plain)\n", source)
+			os.Exit(1)
+		}
+		if *output != "" {
+			dest = *output
+		}
+
+		absDest, _ := filepath.Abs(dest)
+		relDest, err := filepath.Rel(p.RootDir, absDest)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		resolved, err := p.Import(relDest, plaintext, *format)
+		if err != nil {
+			fmt.Printf("Error importing %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Imported (%s): %s → %s%s (%s)\n", source, file, filepath.ToSlash(relDest), project.EncryptedExt, resolved)
+
+		switch {
+		case source == "plain" && absDest == mustAbs(file):
+			if err := os.Remove(file); err != nil {
+				fmt.Printf("⚠️  Could not delete original: %s\n", err)
+			} else {
+				fmt.Printf("🗑️  Deleted original: %s\n", file)
+			}
+		case source != "plain":
+			fmt.Printf("   Remove %s once you have verified the import\n", file)
+		}
+	}
+}
+
+func mustAbs(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+func printVersion() {
+	fmt.Printf("PODX %s\n", Version)
+	fmt.Printf("Build time: %s\n", BuildTime)
+	fmt.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	
+	// Check for updates, unless turned off (update_check: false)
+	if !*loadSettings().UpdateCheck {
+		return
+	}
 	if newVersion, available := updater.CheckUpdate(Version); available {
 		fmt.Printf("\n📦 New version available: %s\n", newVersion)
 		fmt.Println("   Run 'podx update' to upgrade")
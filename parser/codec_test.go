@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// testCipher "encrypts" by reversing the bytes, so tests don't need keys and
+// a value that was never encrypted can't decrypt to the right plaintext
+type testCipher struct{}
+
+func (testCipher) Seal(plaintext []byte) (string, []byte, error) {
+	return "test", reversed(plaintext), nil
+}
+
+func (testCipher) Open(algo string, ciphertext []byte) ([]byte, error) {
+	return reversed(ciphertext), nil
+}
+
+func reversed(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out
+}
+
+type codecTest struct {
+	name      string
+	input     string
+	hidden    []string // Must not appear in the encrypted output
+	plaintext []string // Must stay readable in the encrypted output
+}
+
+// testRoundTrip encrypts each input, checks what is readable, and checks
+// that decryption gives back the exact input
+func testRoundTrip(t *testing.T, format Format, tests []codecTest) {
+	t.Helper()
+	codec, ok := CodecFor(format)
+	if !ok {
+		t.Fatalf("no codec for %s", format)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := codec.Encrypt([]byte(tt.input), testCipher{})
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			for _, s := range tt.hidden {
+				if bytes.Contains(encrypted, []byte(s)) {
+					t.Errorf("encrypted output contains %q:\n%s", s, encrypted)
+				}
+			}
+			for _, s := range tt.plaintext {
+				if !bytes.Contains(encrypted, []byte(s)) {
+					t.Errorf("encrypted output lacks %q:\n%s", s, encrypted)
+				}
+			}
+
+			decrypted, err := codec.Decrypt(encrypted, testCipher{})
+			if err != nil {
+				t.Fatalf("Decrypt: %v\n%s", err, encrypted)
+			}
+			if string(decrypted) != tt.input {
+				t.Errorf("round trip changed the file\nwant:\n%s\ngot:\n%s\nencrypted:\n%s", tt.input, decrypted, encrypted)
+			}
+		})
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	testRoundTrip(t, FormatYAML, []codecTest{
+		{
+			name:      "scalars",
+			input:     "password: hunter2\nport: 5432\nratio: 0.5\nenabled: true\nempty: null\n",
+			hidden:    []string{"hunter2", "5432", "0.5", "true"},
+			plaintext: []string{"password:", "port:", "empty: null"},
+		},
+		{
+			name:      "flow collections",
+			input:     "other: [1, 2]\nhosts: [a, 'b', \"c\"]\nmap: {user: root, id: 7}\n",
+			hidden:    []string{"root"},
+			plaintext: []string{"other:", "map:"},
+		},
+		{
+			name:      "blank lines",
+			input:     "db:\n  user: admin\n\n  pass: secret\n\n\napi:\n  # the key\n  key: abc\n\nlist:\n  - one\n\n  - two\n",
+			hidden:    []string{"admin", "secret", "abc", "one"},
+			plaintext: []string{"db:", "# the key"},
+		},
+		{
+			name:  "blank lines around comments and documents",
+			input: "# c1\n\n# c2\na: 1\n\n# c3\n\n# c4\nb:\n\n  c: 2\n---\nd: 3\n\ne: 4\n\n",
+		},
+		{
+			name:   "sequences of mappings",
+			input:  "items:\n  - name: a\n    pw: x\n\n  - name: b\n    pw: y\n",
+			hidden: []string{"pw: x", "pw: y"},
+		},
+		{
+			name:   "block scalars",
+			input:  "cert: |\n  line one\n\n  line three\n\nnext: x\n",
+			hidden: []string{"line one", "line three"},
+		},
+		{
+			name:      "quoting, comments and anchors",
+			input:     "# header\nbase: &base\n  token: 'abc' # inline\n  name: \"svc\"\nchild:\n  <<: *base\n  extra: 1\n",
+			hidden:    []string{"abc", "svc"},
+			plaintext: []string{"# header", "&base", "*base", "# inline"},
+		},
+		{
+			name:      "kubernetes secret",
+			input:     "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  password: aHVudGVyMg==\nstringData:\n  user: admin\n---\napiVersion: v1\nkind: ConfigMap\ndata:\n  mode: prod\n",
+			hidden:    []string{"aHVudGVyMg==", "admin"},
+			plaintext: []string{"name: db", "mode: prod"},
+		},
+	})
+}
+
+func TestYAMLDecryptKeepsTypes(t *testing.T) {
+	codec, _ := CodecFor(FormatYAML)
+	encrypted, err := codec.Encrypt([]byte("other: [1, 2]\n"), testCipher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := codec.Decrypt(encrypted, testCipher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(decrypted), "'") {
+		t.Errorf("numbers came back quoted: %s", decrypted)
+	}
+}
+
+func TestK8sSecretEncryptsDecodedData(t *testing.T) {
+	codec, _ := CodecFor(FormatYAML)
+	input := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\n  labels:\n    app: api\ntype: Opaque\ndata:\n  password: aHVudGVy\n    Mg==\n"
+	encrypted, err := codec.Encrypt([]byte(input), testCipher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The value is decoded (line breaks in the base64 included) before sealing
+	want := "ENC[test:" + base64.StdEncoding.EncodeToString(reversed([]byte("hunter2"))) + "]"
+	if !strings.Contains(string(encrypted), "password: "+want) {
+		t.Errorf("data value not encrypted in decoded form:\n%s", encrypted)
+	}
+	for _, s := range []string{"name: db", "app: api", "type: Opaque"} {
+		if !strings.Contains(string(encrypted), s) {
+			t.Errorf("encrypted output lacks %q:\n%s", s, encrypted)
+		}
+	}
+
+	decrypted, err := codec.Decrypt(encrypted, testCipher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(decrypted), "password: aHVudGVyMg==\n") {
+		t.Errorf("data value not re-encoded:\n%s", decrypted)
+	}
+}
+
+func TestK8sSecretInvalidBase64(t *testing.T) {
+	codec, _ := CodecFor(FormatYAML)
+	_, err := codec.Encrypt([]byte("apiVersion: v1\nkind: Secret\ndata:\n  password: not-base64!\n"), testCipher{})
+	if err == nil || !strings.Contains(err.Error(), "data.password") {
+		t.Errorf("expected an error naming data.password, got %v", err)
+	}
+}
+
+func TestIsK8sSecret(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want bool
+	}{
+		{"apiVersion: v1\nkind: Secret\n", true},
+		{"kind: Secret\napiVersion: v1\ndata: {}\n", true},
+		{"apiVersion: v1\nkind: ConfigMap\n", false},
+		{"apiVersion: bitnami.com/v1alpha1\nkind: Secret\n", false},
+		{"- kind: Secret\n  apiVersion: v1\n", false},
+		{"kind: Secret\n", false},
+	}
+	for _, tt := range tests {
+		docs, err := decodeYAMLDocuments([]byte(tt.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsK8sSecret(docs[0]); got != tt.want {
+			t.Errorf("IsK8sSecret(%q) = %v, want %v", tt.doc, got, tt.want)
+		}
+	}
+}
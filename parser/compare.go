@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// EnvDiff adalah hasil perbandingan dua file .env
+type EnvDiff struct {
+	OnlyInA []string // Key yang hanya ada di file pertama
+	OnlyInB []string // Key yang hanya ada di file kedua
+	Common  []string // Key yang ada di kedua file
+	Changed []string // Key bersama yang nilainya berbeda (hanya diisi oleh CompareEnvValues)
+}
+
+// HasDifferences mengembalikan true jika ada key yang hilang atau nilai yang berbeda
+func (d *EnvDiff) HasDifferences() bool {
+	return len(d.OnlyInA) > 0 || len(d.OnlyInB) > 0 || len(d.Changed) > 0
+}
+
+// EnvKeys mengembalikan daftar key sesuai urutan kemunculan (tanpa duplikat).
+// Prefix "export " diabaikan, jadi "export A" dan "A" adalah key yang sama.
+func EnvKeys(entries []EnvEntry) []string {
+	var keys []string
+	for _, v := range EnvVars(entries) {
+		keys = append(keys, v.Key)
+	}
+	return keys
+}
+
+// CompareEnvKeys membandingkan himpunan key dua file tanpa mendekripsi nilainya.
+// Nama key disimpan sebagai plaintext di file format-preserving.
+func CompareEnvKeys(a, b []EnvEntry) *EnvDiff {
+	keysA := EnvKeys(a)
+	keysB := EnvKeys(b)
+
+	inA := make(map[string]bool, len(keysA))
+	for _, k := range keysA {
+		inA[k] = true
+	}
+	inB := make(map[string]bool, len(keysB))
+	for _, k := range keysB {
+		inB[k] = true
+	}
+
+	diff := &EnvDiff{}
+	for _, k := range keysA {
+		if inB[k] {
+			diff.Common = append(diff.Common, k)
+		} else {
+			diff.OnlyInA = append(diff.OnlyInA, k)
+		}
+	}
+	for _, k := range keysB {
+		if !inA[k] {
+			diff.OnlyInB = append(diff.OnlyInB, k)
+		}
+	}
+
+	return diff
+}
+
+// CompareEnvValues membandingkan key dan nilai dua file yang sudah didekripsi.
+// Nilai dibandingkan setelah di-unquote, jadi A="x" dan A=x sama. Kalau key
+// muncul lebih dari sekali, nilai terakhir yang dipakai (sama seperti runtime).
+func CompareEnvValues(a, b []EnvEntry) *EnvDiff {
+	diff := CompareEnvKeys(a, b)
+
+	valuesA := EnvValues(a)
+	valuesB := EnvValues(b)
+
+	for _, k := range diff.Common {
+		if valuesA[k] != valuesB[k] {
+			diff.Changed = append(diff.Changed, k)
+		}
+	}
+
+	return diff
+}
+
+// EnvValues mengembalikan nilai (sudah di-unquote) dari setiap key
+func EnvValues(entries []EnvEntry) map[string]string {
+	values := make(map[string]string)
+	for _, v := range EnvVars(entries) {
+		values[v.Key] = v.Value
+	}
+	return values
+}
+
+// ValueHasher membuat fingerprint pendek dari nilai rahasia.
+// Memakai HMAC dengan key acak per proses, jadi hash bisa dibandingkan dalam
+// satu output tapi tidak bisa di-brute-force dari log.
+type ValueHasher struct {
+	key []byte
+}
+
+// NewValueHasher membuat ValueHasher dengan key acak
+func NewValueHasher() (*ValueHasher, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate hash key: %w", err)
+	}
+	return &ValueHasher{key: key}, nil
+}
+
+// Hash mengembalikan fingerprint hex 12 karakter dari value
+func (h *ValueHasher) Hash(value string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareEnvValues(t *testing.T) {
+	a, err := ParseEnv([]byte("export A=1\nB=\"two\"\nC='x'\nD=old\nD=new\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseEnv([]byte("A=1\nexport B=two\nC=y\nD=new\nE=5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := CompareEnvValues(a, b)
+	if want := []string{"A", "B", "C", "D"}; !slices.Equal(diff.Common, want) {
+		t.Errorf("Common = %q, want %q", diff.Common, want)
+	}
+	if len(diff.OnlyInA) != 0 || !slices.Equal(diff.OnlyInB, []string{"E"}) {
+		t.Errorf("OnlyInA = %q, OnlyInB = %q", diff.OnlyInA, diff.OnlyInB)
+	}
+	if want := []string{"C"}; !slices.Equal(diff.Changed, want) {
+		t.Errorf("Changed = %q, want %q", diff.Changed, want)
+	}
+}
@@ -2,8 +2,10 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
@@ -33,8 +35,17 @@ func ParseEnvFile(path string) ([]EnvEntry, error) {
 	}
 	defer file.Close()
 
+	return parseEnv(file)
+}
+
+// ParseEnv mem-parse isi file .env yang sudah ada di memori
+func ParseEnv(data []byte) ([]EnvEntry, error) {
+	return parseEnv(bytes.NewReader(data))
+}
+
+func parseEnv(r io.Reader) ([]EnvEntry, error) {
 	var entries []EnvEntry
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -176,3 +187,180 @@ func DecryptEnvValues(entries []EnvEntry, key []byte) error {
 
 	return nil
 }
+
+// DecryptAgeValues mendekripsi nilai ENC[age:...] dengan Age identity
+func DecryptAgeValues(entries []EnvEntry, identity string) error {
+	for i := range entries {
+		if entries[i].IsComment || !entries[i].Encrypted || entries[i].Algorithm != "age" {
+			continue
+		}
+
+		ciphertext, err := base64.StdEncoding.DecodeString(entries[i].Value)
+		if err != nil {
+			return fmt.Errorf("failed to decode base64 for key '%s': %w", entries[i].Key, err)
+		}
+
+		plaintext, err := crypto.AgeDecrypt(ciphertext, identity)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt key '%s': %w", entries[i].Key, err)
+		}
+
+		entries[i].Value = string(plaintext)
+		entries[i].Encrypted = false
+		entries[i].Algorithm = ""
+	}
+
+	return nil
+}
+
+// dotenvCodec mengenkripsi nilai KEY=VALUE, komentar dan baris kosong tetap apa adanya
+type dotenvCodec struct{}
+
+func (dotenvCodec) Encrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return MapEnvValues(data, func(key, value string) (string, error) {
+		// Skip already encrypted values. Referensi podx:// tetap plaintext
+		// supaya bisa dicek oleh podx status.
+		if IsEncryptedValue(value) || IsSecretRef(value) {
+			return value, nil
+		}
+
+		encValue, err := EncryptValue(c, []byte(value))
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt key '%s': %w", key, err)
+		}
+		return encValue, nil
+	})
+}
+
+func (dotenvCodec) Decrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return MapEnvValues(data, func(key, value string) (string, error) {
+		if !encryptedValuePattern.MatchString(value) {
+			return value, nil
+		}
+
+		plaintext, err := DecryptValue(c, value)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt key '%s': %w", key, err)
+		}
+		return string(plaintext), nil
+	})
+}
+
+// MapEnvValues mengganti nilai mentah setiap baris KEY=VALUE dengan hasil fn.
+// Bagian key (termasuk spasi) dipertahankan byte-per-byte.
+func MapEnvValues(data []byte, fn func(key, value string) (string, error)) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// Keep comments and empty lines
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		idx := strings.Index(line, "=")
+		if idx == -1 {
+			continue
+		}
+
+		value, err := fn(strings.TrimSpace(line[:idx]), line[idx+1:])
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = line[:idx+1] + value
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// EnvVar adalah pasangan key/value dari file .env yang sudah didekripsi
+type EnvVar struct {
+	Key     string
+	Value   string
+	Literal bool // Nilai dalam tanda kutip tunggal, tidak di-interpolasi
+}
+
+// EnvVars mengubah entries menjadi daftar variabel sesuai urutan kemunculan.
+// Tanda kutip di-unquote dan key duplikat memakai nilai terakhir (sama seperti runtime).
+func EnvVars(entries []EnvEntry) []EnvVar {
+	var vars []EnvVar
+	index := make(map[string]int)
+
+	for _, entry := range entries {
+		if entry.IsComment {
+			continue
+		}
+
+		key := strings.TrimSpace(strings.TrimPrefix(entry.Key, "export "))
+		value := UnquoteValue(entry.Value)
+		literal := strings.HasPrefix(strings.TrimSpace(entry.Value), "'")
+
+		if i, ok := index[key]; ok {
+			vars[i].Value, vars[i].Literal = value, literal
+			continue
+		}
+		index[key] = len(vars)
+		vars = append(vars, EnvVar{Key: key, Value: value, Literal: literal})
+	}
+
+	return vars
+}
+
+// QuoteValue menulis value sebagai nilai .env yang dibaca kembali sama
+// persis oleh UnquoteValue: tanpa tanda kutip kalau aman, '...' kalau
+// bisa, selain itu "..." dengan escape
+func QuoteValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n#'\"\\$") {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\r\n") {
+		return "'" + value + "'"
+	}
+
+	r := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\r", "\\r", "\t", "\\t")
+	return "\"" + r.Replace(value) + "\""
+}
+
+// UnquoteValue mengembalikan nilai .env seperti yang dibaca aplikasi:
+// "..." mendukung escape (\n, \", \\), '...' literal, dan nilai tanpa
+// tanda kutip dipotong di komentar " #"
+func UnquoteValue(value string) string {
+	value = strings.TrimSpace(value)
+
+	if len(value) >= 2 && value[0] == '\'' {
+		if end := strings.IndexByte(value[1:], '\''); end != -1 {
+			return value[1 : end+1]
+		}
+	}
+
+	if len(value) >= 2 && value[0] == '"' {
+		var sb strings.Builder
+		for i := 1; i < len(value); i++ {
+			ch := value[i]
+			if ch == '"' {
+				return sb.String()
+			}
+			if ch == '\\' && i+1 < len(value) {
+				i++
+				switch value[i] {
+				case 'n':
+					sb.WriteByte('\n')
+				case 'r':
+					sb.WriteByte('\r')
+				case 't':
+					sb.WriteByte('\t')
+				default:
+					sb.WriteByte(value[i])
+				}
+				continue
+			}
+			sb.WriteByte(ch)
+		}
+	}
+
+	if idx := strings.Index(value, " #"); idx != -1 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	return value
+}
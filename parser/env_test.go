@@ -0,0 +1,37 @@
+package parser
+
+import "testing"
+
+func TestQuoteValue(t *testing.T) {
+	for _, value := range []string{"plain", "", "p@ss word", "a#b", "it's", "line\none", `back\slash "q"`, "$HOME", " padded "} {
+		if got := UnquoteValue(QuoteValue(value)); got != value {
+			t.Errorf("UnquoteValue(QuoteValue(%q)) = %q (quoted: %s)", value, got, QuoteValue(value))
+		}
+	}
+}
+
+func TestDotenvRoundTrip(t *testing.T) {
+	testRoundTrip(t, FormatDotenv, []codecTest{
+		{
+			name:      "quoting and comments",
+			input:     "# database\nDB_USER=admin\nDB_PASS=\"hunter 2\"\nLITERAL='a$b'\n\nexport TOKEN=abc123 # inline\n",
+			hidden:    []string{"admin", "hunter 2", "a$b", "abc123"},
+			plaintext: []string{"# database", "DB_USER=", "export TOKEN="},
+		},
+		{
+			name:      "spacing",
+			input:     "SPACED = value\nEQUALS=a=b=c\n",
+			hidden:    []string{"value", "a=b=c"},
+			plaintext: []string{"SPACED ="},
+		},
+		{
+			name:      "references stay readable",
+			input:     "DB_PASS=podx://shared/db.env#PASS\n",
+			plaintext: []string{"podx://shared/db.env#PASS"},
+		},
+		{
+			name:  "CRLF and no trailing newline",
+			input: "A=1\r\nB=2",
+		},
+	})
+}
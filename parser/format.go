@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format adalah format file secret
+type Format string
+
+const (
+	FormatAuto   Format = "auto"
+	FormatDotenv Format = "dotenv"
+	FormatYAML   Format = "yaml"
+	FormatJSON   Format = "json"
+	FormatINI    Format = "ini"
+	FormatPEM    Format = "pem"
+	FormatBinary Format = "binary"
+)
+
+// Codec mengenkripsi nilai di dalam file tanpa mengubah strukturnya
+type Codec interface {
+	Encrypt(data []byte, c ValueCipher) ([]byte, error)
+	Decrypt(data []byte, c ValueCipher) ([]byte, error)
+}
+
+// codecs berisi codec format-preserving yang tersedia
+var codecs = map[Format]Codec{
+	FormatDotenv: dotenvCodec{},
+	FormatYAML:   yamlCodec{},
+	FormatJSON:   jsonCodec{},
+	FormatINI:    iniCodec{},
+	FormatPEM:    pemCodec{},
+}
+
+// ParseFormat memvalidasi nama format dari .podx.yaml (kosong berarti auto)
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatAuto, nil
+	case FormatAuto, FormatDotenv, FormatYAML, FormatJSON, FormatINI, FormatPEM, FormatBinary:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format '%s' (supported: dotenv, yaml, json, ini, pem, binary, auto)", s)
+	}
+}
+
+// CodecFor mengembalikan codec untuk format, false jika format harus
+// dienkripsi sebagai binary
+func CodecFor(f Format) (Codec, bool) {
+	c, ok := codecs[f]
+	return c, ok
+}
+
+var (
+	envLinePattern     = regexp.MustCompile(`^(export\s+)?[A-Za-z_][A-Za-z0-9_.]*\s*=`)
+	iniSectionPattern  = regexp.MustCompile(`^\[[^\]]+\]$`)
+	iniLinePattern     = regexp.MustCompile(`^[^=:\s][^=:]*\s*[=:]`)
+	yamlMappingPattern = regexp.MustCompile(`^[^\s#][^:]*:(\s|$)`)
+)
+
+// DetectFormat menebak format file dari nama dan isinya
+func DetectFormat(name string, data []byte) Format {
+	base := filepath.Base(name)
+	lower := strings.ToLower(base)
+
+	// Petunjuk dari nama file
+	switch {
+	case strings.HasPrefix(base, ".env") || strings.HasSuffix(base, ".env") || strings.Contains(base, ".env."):
+		return FormatDotenv
+	case strings.HasSuffix(lower, ".yaml") || strings.HasSuffix(lower, ".yml"):
+		return FormatYAML
+	case strings.HasSuffix(lower, ".json"):
+		return FormatJSON
+	case strings.HasSuffix(lower, ".ini") || strings.HasSuffix(lower, ".properties"):
+		return FormatINI
+	case strings.HasSuffix(lower, ".pem") && bytes.Contains(data, []byte("-----BEGIN ")):
+		return FormatPEM
+	}
+
+	return SniffFormat(data)
+}
+
+// SniffFormat menebak format hanya dari isi file
+func SniffFormat(data []byte) Format {
+	if len(data) == 0 || !utf8.Valid(data) || bytes.IndexByte(data, 0) != -1 {
+		return FormatBinary
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("-----BEGIN ")) {
+		return FormatPEM
+	}
+	if (bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("["))) && json.Valid(trimmed) {
+		return FormatJSON
+	}
+
+	// Hitung baris yang cocok dengan tiap format
+	var env, ini, yml, other int
+	hasSection := false
+	for _, line := range strings.Split(string(data), "\n") {
+		t := strings.TrimSpace(line)
+		if t == "" || strings.HasPrefix(t, "#") || strings.HasPrefix(t, ";") {
+			continue
+		}
+		switch {
+		case iniSectionPattern.MatchString(t):
+			hasSection = true
+			ini++
+		case envLinePattern.MatchString(t):
+			env++
+		case yamlMappingPattern.MatchString(line) || strings.HasPrefix(t, "- ") || t == "---":
+			yml++
+		case iniLinePattern.MatchString(t):
+			ini++
+		default:
+			other++
+		}
+	}
+
+	switch {
+	case hasSection:
+		return FormatINI
+	case env > 0 && yml == 0 && ini == 0 && other == 0:
+		return FormatDotenv
+	case yml > 0 && other == 0:
+		return FormatYAML
+	case ini > 0 && other == 0:
+		return FormatINI
+	default:
+		return FormatBinary
+	}
+}
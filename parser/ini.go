@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// iniCodec mengenkripsi nilai di file INI dan Java .properties.
+// Section, komentar (#, ; dan !), komentar inline (" ; ...") dan separator
+// (=, : atau spasi) tetap apa adanya. Nilai yang disambung dengan '\' di
+// akhir baris dienkripsi sebagai satu nilai, jadi dekripsi mengembalikan
+// file yang sama persis.
+type iniCodec struct{}
+
+func (iniCodec) Encrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return mapINIValues(data, func(key, value string) (string, error) {
+		// Skip already encrypted values
+		if IsEncryptedValue(value) {
+			return value, nil
+		}
+
+		encValue, err := EncryptValue(c, []byte(value))
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt key '%s': %w", key, err)
+		}
+		return encValue, nil
+	})
+}
+
+func (iniCodec) Decrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return mapINIValues(data, func(key, value string) (string, error) {
+		if !encryptedValuePattern.MatchString(value) {
+			return value, nil
+		}
+
+		plaintext, err := DecryptValue(c, value)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt key '%s': %w", key, err)
+		}
+		return string(plaintext), nil
+	})
+}
+
+// mapINIValues mengganti nilai setiap entry key=value dengan hasil fn.
+// Key di dalam section dilaporkan sebagai "section.key".
+func mapINIValues(data []byte, fn func(key, value string) (string, error)) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+	var result []string
+	section := ""
+
+	for i := 0; i < len(lines); i++ {
+		line, eol := strings.TrimSuffix(lines[i], "\r"), ""
+		if strings.HasSuffix(lines[i], "\r") {
+			eol = "\r"
+		}
+		trimmed := strings.TrimSpace(line)
+
+		// Keep comments, empty lines and section headers
+		if trimmed == "" || strings.ContainsAny(trimmed[:1], "#;!") {
+			result = append(result, lines[i])
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			result = append(result, lines[i])
+			continue
+		}
+
+		keyEnd, valueStart := splitINILine(line)
+		if keyEnd == -1 {
+			result = append(result, lines[i])
+			continue
+		}
+
+		// Gabungkan baris lanjutan (diakhiri backslash) ke dalam nilai.
+		// Komentar inline hanya dikenali di nilai satu baris.
+		value, comment := line[valueStart:], ""
+		if continuesINILine(value) {
+			for continuesINILine(value) && i+1 < len(lines) {
+				i++
+				value += eol + "\n" + strings.TrimSuffix(lines[i], "\r")
+			}
+		} else {
+			value, comment = splitINIComment(value)
+		}
+
+		key := strings.TrimSpace(line[:keyEnd])
+		if section != "" {
+			key = section + "." + key
+		}
+
+		newValue, err := fn(key, value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, line[:valueStart]+newValue+comment+eol)
+	}
+
+	return []byte(strings.Join(result, "\n")), nil
+}
+
+// splitINILine mencari akhir key dan awal nilai. Separator adalah '=' atau
+// ':' pertama yang tidak di-escape, jadi key boleh berisi spasi
+// ("my key = value"). Hanya kalau keduanya tidak ada, whitespace pertama
+// dipakai (format .properties "key value"). Mengembalikan -1 jika baris
+// tidak punya separator.
+func splitINILine(line string) (keyEnd, valueStart int) {
+	start := len(line) - len(strings.TrimLeft(line, " \t\f"))
+
+	// ':' dan '=' di dalam ENC[algo:base64=] bukan separator
+	keyPart := line
+	if i := strings.Index(line, "ENC["); i != -1 {
+		keyPart = line[:i]
+	}
+
+	keyEnd = indexINISeparator(keyPart, start, "=:")
+	if keyEnd == -1 {
+		keyEnd = indexINISeparator(line, start, " \t\f")
+	}
+	if keyEnd == -1 {
+		return -1, -1
+	}
+
+	// Lewati whitespace, satu '=' atau ':', lalu whitespace lagi
+	j := keyEnd
+	for j < len(line) && strings.IndexByte(" \t\f", line[j]) != -1 {
+		j++
+	}
+	if j < len(line) && (line[j] == '=' || line[j] == ':') {
+		j++
+		for j < len(line) && strings.IndexByte(" \t\f", line[j]) != -1 {
+			j++
+		}
+	}
+
+	return keyEnd, j
+}
+
+// indexINISeparator mengembalikan posisi karakter pertama dari seps yang
+// tidak di-escape, mulai dari start, atau -1
+func indexINISeparator(line string, start int, seps string) int {
+	for i := start; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte(seps, line[i]) != -1 {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitINIComment memisahkan komentar inline dari nilai: ';' yang didahului
+// whitespace, di luar nilai yang diapit tanda kutip. Whitespace sebelum ';'
+// ikut ke komentar, jadi "secret ; note" menjadi "secret" dan " ; note".
+func splitINIComment(value string) (string, string) {
+	i := 0
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end != -1 {
+			i = end + 2
+		}
+	}
+
+	for ; i < len(value); i++ {
+		if value[i] == ';' && i > 0 && (value[i-1] == ' ' || value[i-1] == '\t') {
+			end := strings.TrimRight(value[:i], " \t")
+			return end, value[len(end):]
+		}
+	}
+	return value, ""
+}
+
+// continuesINILine mengecek apakah nilai diakhiri backslash yang tidak di-escape
+func continuesINILine(value string) bool {
+	n := 0
+	for i := len(value) - 1; i >= 0 && value[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
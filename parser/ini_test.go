@@ -0,0 +1,36 @@
+package parser
+
+import "testing"
+
+func TestINIRoundTrip(t *testing.T) {
+	testRoundTrip(t, FormatINI, []codecTest{
+		{
+			name:      "sections and comments",
+			input:     "; global\n[database]\nuser = admin\npassword=hunter2\n\n# other\n[api]\nkey: abc\n",
+			hidden:    []string{"admin", "hunter2", "abc"},
+			plaintext: []string{"; global", "[database]", "user = ", "password=", "# other", "key: "},
+		},
+		{
+			name:      "properties",
+			input:     "db.url=jdbc:postgresql://localhost/app\nmulti=first \\\n  second\n! comment\n",
+			hidden:    []string{"jdbc", "first", "second"},
+			plaintext: []string{"db.url=", "multi=", "! comment"},
+		},
+		{
+			name:  "CRLF",
+			input: "[s]\r\na=1\r\nb=2\r\n",
+		},
+		{
+			name:      "inline comments",
+			input:     "password = hunter2 ; rotated monthly\ntoken=\"a ; b\" ; quoted\nnote = don't ; ask\nurl = a;b\n",
+			hidden:    []string{"hunter2", "a ; b", "don't", "a;b"},
+			plaintext: []string{" ; rotated monthly", " ; quoted", " ; ask"},
+		},
+		{
+			name:      "keys with spaces",
+			input:     "[server]\nlisten address = 0.0.0.0\nadmin password: s3cret\nplain value\n",
+			hidden:    []string{"0.0.0.0", "s3cret"},
+			plaintext: []string{"listen address = ", "admin password: ", "plain "},
+		},
+	})
+}
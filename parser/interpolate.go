@@ -0,0 +1,214 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Interpolate mengekspansi referensi ${VAR}, $VAR, ${VAR:-default} dan
+// ${VAR-default} di nilai variabel. Referensi dicari di vars dulu, lalu di
+// fallback (misalnya os.LookupEnv, boleh nil). $$ menghasilkan '$' dan nilai
+// dalam tanda kutip tunggal tidak diekspansi.
+//
+// Referensi melingkar selalu error. Dengan strict, referensi ke variabel yang
+// tidak terdefinisi (dan tanpa default) juga error; tanpa strict menjadi "".
+func Interpolate(vars []EnvVar, strict bool, fallback func(string) (string, bool)) ([]EnvVar, error) {
+	in := &interpolator{
+		vars:     make(map[string]*EnvVar, len(vars)),
+		resolved: make(map[string]string, len(vars)),
+		visiting: make(map[string]bool),
+		strict:   strict,
+		fallback: fallback,
+	}
+	for i := range vars {
+		in.vars[vars[i].Key] = &vars[i]
+	}
+
+	result := make([]EnvVar, len(vars))
+	for i, v := range vars {
+		value, err := in.resolve(v.Key, nil)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = EnvVar{Key: v.Key, Value: value, Literal: v.Literal}
+	}
+	return result, nil
+}
+
+type interpolator struct {
+	vars     map[string]*EnvVar
+	resolved map[string]string
+	visiting map[string]bool
+	strict   bool
+	fallback func(string) (string, bool)
+}
+
+// resolve mengembalikan nilai key setelah diekspansi. chain dipakai untuk
+// pesan error saat ada siklus (A -> B -> A).
+func (in *interpolator) resolve(key string, chain []string) (string, error) {
+	if value, ok := in.resolved[key]; ok {
+		return value, nil
+	}
+
+	v := in.vars[key]
+	chain = append(chain, key)
+	if in.visiting[key] {
+		return "", fmt.Errorf("circular reference: %s", strings.Join(chain, " -> "))
+	}
+	if v.Literal {
+		in.resolved[key] = v.Value
+		return v.Value, nil
+	}
+
+	in.visiting[key] = true
+	value, err := in.expand(v.Value, key, chain)
+	delete(in.visiting, key)
+	if err != nil {
+		return "", err
+	}
+
+	in.resolved[key] = value
+	return value, nil
+}
+
+// lookup mencari nilai referensi name dari variabel di file atau fallback
+func (in *interpolator) lookup(name string, chain []string) (string, bool, error) {
+	if _, ok := in.vars[name]; ok {
+		value, err := in.resolve(name, chain)
+		return value, true, err
+	}
+	if in.fallback != nil {
+		if value, ok := in.fallback(name); ok {
+			return value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func (in *interpolator) expand(s, key string, chain []string) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			sb.WriteByte('$')
+			i++
+
+		case next == '{':
+			end := matchingBrace(s, i+2)
+			if end == -1 {
+				return "", fmt.Errorf("%s: unterminated '${' in value", key)
+			}
+			value, err := in.expandBraced(s[i+2:end], key, chain)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(value)
+			i = end
+
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			value, err := in.reference(s[i+1:j], key, chain)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(value)
+			i = j - 1
+
+		default:
+			sb.WriteByte('$')
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// expandBraced menangani isi ${...}: NAME, NAME:-default atau NAME-default
+func (in *interpolator) expandBraced(expr, key string, chain []string) (string, error) {
+	name, def, hasDefault := expr, "", false
+	emptyIsUnset := false
+	if i := strings.IndexAny(expr, ":-"); i != -1 {
+		name = expr[:i]
+		switch {
+		case strings.HasPrefix(expr[i:], ":-"):
+			def, hasDefault, emptyIsUnset = expr[i+2:], true, true
+		case expr[i] == '-':
+			def, hasDefault = expr[i+1:], true
+		default:
+			return "", fmt.Errorf("%s: unsupported expansion '${%s}'", key, expr)
+		}
+	}
+	if !validName(name) {
+		return "", fmt.Errorf("%s: invalid variable name in '${%s}'", key, expr)
+	}
+
+	if !hasDefault {
+		return in.reference(name, key, chain)
+	}
+
+	value, ok, err := in.lookup(name, chain)
+	if err != nil {
+		return "", err
+	}
+	if ok && (value != "" || !emptyIsUnset) {
+		return value, nil
+	}
+	// Default sendiri boleh berisi referensi
+	return in.expand(def, key, chain)
+}
+
+func (in *interpolator) reference(name, key string, chain []string) (string, error) {
+	value, ok, err := in.lookup(name, chain)
+	if err != nil {
+		return "", err
+	}
+	if !ok && in.strict {
+		return "", fmt.Errorf("%s: undefined variable '%s'", key, name)
+	}
+	return value, nil
+}
+
+// matchingBrace mencari '}' penutup, memperhitungkan ${...} bersarang di default
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(ch byte) bool {
+	return ch == '_' || ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z'
+}
+
+func isNameChar(ch byte) bool {
+	return isNameStart(ch) || ch >= '0' && ch <= '9'
+}
+
+func validName(name string) bool {
+	if name == "" || !isNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// envVars builds variables from "KEY=value" pairs; a value starting with '
+// is literal (single-quoted in the file)
+func envVars(pairs ...string) []EnvVar {
+	var vars []EnvVar
+	for _, p := range pairs {
+		key, value, _ := strings.Cut(p, "=")
+		literal := strings.HasPrefix(value, "'")
+		vars = append(vars, EnvVar{Key: key, Value: strings.Trim(value, "'"), Literal: literal})
+	}
+	return vars
+}
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"HOME": "/home/ci", "EMPTY_ENV": ""}
+	fallback := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		name   string
+		vars   []EnvVar
+		strict bool
+		want   map[string]string
+	}{
+		{
+			name: "braced and bare references",
+			vars: envVars("HOST=db", "PORT=5432", "URL=postgres://${HOST}:$PORT/app"),
+			want: map[string]string{"URL": "postgres://db:5432/app"},
+		},
+		{
+			name: "chained and forward references",
+			vars: envVars("C=${B}!", "B=${A}b", "A=a"),
+			want: map[string]string{"C": "ab!", "B": "ab"},
+		},
+		{
+			name: "colon default replaces unset and empty",
+			vars: envVars("EMPTY=", "A=${EMPTY:-x}", "B=${UNSET:-y}", "C=${EMPTY_ENV:-z}"),
+			want: map[string]string{"A": "x", "B": "y", "C": "z"},
+		},
+		{
+			name: "dash default replaces only unset",
+			vars: envVars("EMPTY=", "A=${EMPTY-x}", "B=${UNSET-y}", "C=${EMPTY_ENV-z}"),
+			want: map[string]string{"A": "", "B": "y", "C": ""},
+		},
+		{
+			name: "defaults may reference other variables",
+			vars: envVars("FALLBACK=fb", "A=${UNSET:-${FALLBACK}-x}"),
+			want: map[string]string{"A": "fb-x"},
+		},
+		{
+			name: "dollar escapes",
+			vars: envVars("A=$$HOME", "B=cost: 5$", "C=$1 and $-", "D=a$${X}"),
+			want: map[string]string{"A": "$HOME", "B": "cost: 5$", "C": "$1 and $-", "D": "a${X}"},
+		},
+		{
+			name: "single-quoted values stay literal",
+			vars: envVars("A=x", "B='${A} $A'", "C=${B}"),
+			want: map[string]string{"B": "${A} $A", "C": "${A} $A"},
+		},
+		{
+			name: "file values win over the environment",
+			vars: envVars("HOME=/srv", "A=$HOME/bin"),
+			want: map[string]string{"A": "/srv/bin"},
+		},
+		{
+			name: "environment fallback",
+			vars: envVars("A=$HOME/bin", "B=${HOME:-none}"),
+			want: map[string]string{"A": "/home/ci/bin", "B": "/home/ci"},
+		},
+		{
+			name: "undefined without strict",
+			vars: envVars("A=[${UNSET}]", "B=[$UNSET]"),
+			want: map[string]string{"A": "[]", "B": "[]"},
+		},
+		{
+			name:   "defaults satisfy strict",
+			vars:   envVars("A=${UNSET:-x}", "B=${UNSET-y}"),
+			strict: true,
+			want:   map[string]string{"A": "x", "B": "y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Interpolate(tt.vars, tt.strict, fallback)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.vars) {
+				t.Fatalf("got %d variables, want %d", len(got), len(tt.vars))
+			}
+			values := make(map[string]string)
+			for i, v := range got {
+				if v.Key != tt.vars[i].Key {
+					t.Errorf("order changed: %q at %d", v.Key, i)
+				}
+				values[v.Key] = v.Value
+			}
+			for key, want := range tt.want {
+				if values[key] != want {
+					t.Errorf("%s = %q, want %q", key, values[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		vars   []EnvVar
+		strict bool
+		want   string
+	}{
+		{
+			name: "self reference",
+			vars: envVars("A=x${A}"),
+			want: "circular reference: A -> A",
+		},
+		{
+			name: "cycle",
+			vars: envVars("A=${B}", "B=${C}", "C=$A"),
+			want: "circular reference: A -> B -> C -> A",
+		},
+		{
+			name: "cycle through a default",
+			vars: envVars("A=${UNSET:-$B}", "B=${A}"),
+			want: "circular reference",
+		},
+		{
+			name:   "strict undefined",
+			vars:   envVars("A=${MISSING}"),
+			strict: true,
+			want:   "A: undefined variable 'MISSING'",
+		},
+		{
+			name:   "strict undefined bare reference",
+			vars:   envVars("A=x", "B=$A$MISSING"),
+			strict: true,
+			want:   "B: undefined variable 'MISSING'",
+		},
+		{
+			name: "unterminated",
+			vars: envVars("A=${B"),
+			want: "unterminated",
+		},
+		{
+			name: "unsupported expansion",
+			vars: envVars("A=${B:?required}"),
+			want: "unsupported expansion",
+		},
+		{
+			name: "invalid name",
+			vars: envVars("A=${1B}"),
+			want: "invalid variable name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Interpolate(tt.vars, tt.strict, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateLookupEnv(t *testing.T) {
+	t.Setenv("PODX_TEST_VAR", "set")
+
+	got, err := Interpolate(envVars("A=$PODX_TEST_VAR/x"), true, os.LookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Value != "set/x" {
+		t.Errorf("A = %q, want %q", got[0].Value, "set/x")
+	}
+
+	// Without a fallback the process environment is not consulted
+	if _, err := Interpolate(envVars("A=$PODX_TEST_VAR"), true, nil); err == nil {
+		t.Error("expected an undefined variable error without a fallback")
+	}
+}
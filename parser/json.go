@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonCodec mengenkripsi leaf string, number dan bool di file JSON menjadi
+// "ENC[...]". File ditulis ulang byte-per-byte, jadi urutan key, indentasi
+// dan whitespace tidak berubah. String yang escape-nya tidak bisa dibuat
+// ulang oleh jsonQuote disimpan sebagai token mentah (type:json).
+type jsonCodec struct{}
+
+// jsonLeaf adalah satu nilai leaf di dokumen JSON
+type jsonLeaf struct {
+	Kind string // string, number, bool atau null
+	Raw  string // Token asli, termasuk tanda kutip untuk string
+}
+
+func (jsonCodec) Encrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return mapJSONLeaves(data, func(path []string, leaf jsonLeaf) (string, error) {
+		var plaintext, typ string
+		switch leaf.Kind {
+		case "null":
+			return leaf.Raw, nil
+		case "string":
+			if err := json.Unmarshal([]byte(leaf.Raw), &plaintext); err != nil {
+				return "", err
+			}
+			// Skip already encrypted values
+			if IsEncryptedValue(plaintext) {
+				return leaf.Raw, nil
+			}
+			// Escape seperti \u00e9 atau \/ hilang kalau string di-quote
+			// ulang, jadi simpan token aslinya dengan type json
+			quoted, err := jsonQuote(plaintext)
+			if err != nil {
+				return "", err
+			}
+			if quoted != leaf.Raw {
+				plaintext, typ = leaf.Raw, "json"
+			}
+		case "number":
+			plaintext, typ = leaf.Raw, "float"
+			if !strings.ContainsAny(leaf.Raw, ".eE") {
+				typ = "int"
+			}
+		case "bool":
+			plaintext, typ = leaf.Raw, "bool"
+		}
+
+		encValue, err := EncryptTypedValue(c, []byte(plaintext), typ)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt key '%s': %w", strings.Join(path, "."), err)
+		}
+		return `"` + encValue + `"`, nil
+	})
+}
+
+func (jsonCodec) Decrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return mapJSONLeaves(data, func(path []string, leaf jsonLeaf) (string, error) {
+		if leaf.Kind != "string" {
+			return leaf.Raw, nil
+		}
+
+		var value string
+		if err := json.Unmarshal([]byte(leaf.Raw), &value); err != nil {
+			return "", err
+		}
+		if !encryptedValuePattern.MatchString(value) {
+			return leaf.Raw, nil
+		}
+
+		plaintext, typ, err := DecryptTypedValue(c, value)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt key '%s': %w", strings.Join(path, "."), err)
+		}
+
+		// Number, bool dan string bertipe json dikembalikan sebagai token aslinya
+		if typ != "str" {
+			return string(plaintext), nil
+		}
+		return jsonQuote(string(plaintext))
+	})
+}
+
+// jsonQuote meng-encode string JSON tanpa escaping HTML (<, >, &)
+func jsonQuote(s string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// mapJSONLeaves mengganti setiap leaf (bukan key) dengan hasil fn dan
+// menyalin sisa dokumen apa adanya
+func mapJSONLeaves(data []byte, fn func(path []string, leaf jsonLeaf) (string, error)) ([]byte, error) {
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+
+	s := &jsonScanner{data: data, fn: fn}
+	s.copySpace()
+	if err := s.value(nil); err != nil {
+		return nil, err
+	}
+	s.copySpace()
+
+	return s.out.Bytes(), nil
+}
+
+// jsonScanner menelusuri dokumen JSON yang sudah divalidasi
+type jsonScanner struct {
+	data []byte
+	pos  int
+	out  bytes.Buffer
+	fn   func(path []string, leaf jsonLeaf) (string, error)
+}
+
+func (s *jsonScanner) copySpace() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n", s.data[s.pos]) != -1 {
+		s.out.WriteByte(s.data[s.pos])
+		s.pos++
+	}
+}
+
+// copyByte menyalin satu karakter struktur ({, }, [, ], :, ,)
+func (s *jsonScanner) copyByte() byte {
+	b := s.data[s.pos]
+	s.out.WriteByte(b)
+	s.pos++
+	return b
+}
+
+func (s *jsonScanner) value(path []string) error {
+	switch s.data[s.pos] {
+	case '{':
+		return s.object(path)
+	case '[':
+		return s.array(path)
+	}
+
+	leaf := s.leaf()
+	replacement, err := s.fn(path, leaf)
+	if err != nil {
+		return err
+	}
+	s.out.WriteString(replacement)
+	return nil
+}
+
+func (s *jsonScanner) object(path []string) error {
+	s.copyByte() // {
+	s.copySpace()
+	if s.data[s.pos] == '}' {
+		s.copyByte()
+		return nil
+	}
+
+	for {
+		raw := s.stringToken()
+		s.out.WriteString(raw)
+
+		var key string
+		if err := json.Unmarshal([]byte(raw), &key); err != nil {
+			return err
+		}
+
+		s.copySpace()
+		s.copyByte() // :
+		s.copySpace()
+		if err := s.value(append(path, key)); err != nil {
+			return err
+		}
+		s.copySpace()
+		if s.copyByte() == '}' {
+			return nil
+		}
+		s.copySpace()
+	}
+}
+
+func (s *jsonScanner) array(path []string) error {
+	s.copyByte() // [
+	s.copySpace()
+	if s.data[s.pos] == ']' {
+		s.copyByte()
+		return nil
+	}
+
+	for i := 0; ; i++ {
+		if err := s.value(append(path, fmt.Sprint(i))); err != nil {
+			return err
+		}
+		s.copySpace()
+		if s.copyByte() == ']' {
+			return nil
+		}
+		s.copySpace()
+	}
+}
+
+// leaf membaca token string, number, true, false atau null
+func (s *jsonScanner) leaf() jsonLeaf {
+	switch s.data[s.pos] {
+	case '"':
+		return jsonLeaf{Kind: "string", Raw: s.stringToken()}
+	case 't', 'f':
+		return jsonLeaf{Kind: "bool", Raw: s.literalToken()}
+	case 'n':
+		return jsonLeaf{Kind: "null", Raw: s.literalToken()}
+	default:
+		return jsonLeaf{Kind: "number", Raw: s.literalToken()}
+	}
+}
+
+func (s *jsonScanner) stringToken() string {
+	start := s.pos
+	s.pos++ // "
+	for s.data[s.pos] != '"' {
+		if s.data[s.pos] == '\\' {
+			s.pos++
+		}
+		s.pos++
+	}
+	s.pos++
+	return string(s.data[start:s.pos])
+}
+
+func (s *jsonScanner) literalToken() string {
+	start := s.pos
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n,]}", s.data[s.pos]) == -1 {
+		s.pos++
+	}
+	return string(s.data[start:s.pos])
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	testRoundTrip(t, FormatJSON, []codecTest{
+		{
+			name:      "nested values",
+			input:     "{\n  \"db\": {\n    \"user\": \"admin\",\n    \"port\": 5432,\n    \"ratio\": 1.5e3,\n    \"tls\": false,\n    \"replica\": null\n  },\n  \"hosts\": [\"a.example\", \"b.example\"]\n}\n",
+			hidden:    []string{"admin", "5432", "1.5e3", "false", "a.example"},
+			plaintext: []string{"\"db\":", "\"replica\": null"},
+		},
+		{
+			name:   "escapes and compact layout",
+			input:  `{"a":"line\nbreak \"q\" <tag> é","b":[],"c":{}}`,
+			hidden: []string{"break", "<tag>"},
+		},
+		{
+			name:      "escapes kept as written",
+			input:     `{"name": "Ren\u00e9e", "url": "https:\/\/example.com\/a", "tab": "a\u0009b", "plain": "caf\u00e9\n"}`,
+			hidden:    []string{"Ren", "example.com", "caf"},
+			plaintext: []string{`"name": "ENC[`, `,type:json]"`},
+		},
+		{
+			name:  "top-level array",
+			input: "[1, \"two\", true]",
+		},
+	})
+}
+
+func TestJSONRawTokenOnlyWhenNeeded(t *testing.T) {
+	codec, _ := CodecFor(FormatJSON)
+	encrypted, err := codec.Encrypt([]byte(`{"a": "plain \"q\" é", "b": "\u00e9"}`), testCipher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(encrypted), ",type:json]"); got != 1 {
+		t.Errorf("want only b stored as a raw token, got %d:\n%s", got, encrypted)
+	}
+}
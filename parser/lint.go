@@ -0,0 +1,222 @@
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hades/podx/crypto"
+)
+
+// Severity tingkat diagnostic lint
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic adalah satu temuan lint di file .env
+type Diagnostic struct {
+	Line     int // Nomor baris, mulai dari 1
+	Severity Severity
+	Message  string
+	Fixable  bool // Bisa diperbaiki otomatis oleh FixEnv
+}
+
+func (d Diagnostic) String() string {
+	fix := ""
+	if d.Fixable {
+		fix = " (fixable)"
+	}
+	return fmt.Sprintf("%d: %s: %s%s", d.Line, d.Severity, d.Message, fix)
+}
+
+// envKeyPattern adalah nama variabel environment yang valid (POSIX)
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// valueAlgorithms adalah algoritma yang dikenal di ENC[algo:...]
+var valueAlgorithms = []string{"age", string(crypto.AlgoAESGCM), string(crypto.AlgoChaCha20)}
+
+// LintEnv memeriksa file .env: key duplikat, nama key tidak valid, baris
+// tanpa '=' yang diam-diam jadi komentar, nilai ENC[...] yang rusak atau
+// algoritmanya tidak dikenal, campuran age dan password, dan (jika
+// encrypted true) nilai yang belum dienkripsi.
+func LintEnv(data []byte, encrypted bool) []Diagnostic {
+	var diags []Diagnostic
+	entries := envEntries(envLines(data))
+
+	lastLine := make(map[string]int)
+	for _, e := range entries {
+		if !e.Entry.IsComment {
+			lastLine[envKey(e.Entry.Key)] = e.Line
+		}
+	}
+
+	algorithms := make(map[string]int) // age / password -> baris pertama
+	for _, e := range entries {
+		n := e.Line + 1
+		entry := e.Entry
+
+		if entry.IsComment {
+			if trimmed := strings.TrimSpace(entry.Raw); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				diags = append(diags, Diagnostic{n, SeverityError, "line is not KEY=VALUE and is ignored as a comment", true})
+			}
+			continue
+		}
+
+		key := envKey(entry.Key)
+		if !envKeyPattern.MatchString(key) {
+			diags = append(diags, Diagnostic{n, SeverityError, fmt.Sprintf("invalid key name '%s'", key), false})
+		}
+		if last := lastLine[key]; last != e.Line {
+			diags = append(diags, Diagnostic{n, SeverityWarning, fmt.Sprintf("duplicate key '%s' is overridden on line %d", key, last+1), true})
+		}
+		if e.Unterminated {
+			diags = append(diags, Diagnostic{n, SeverityError, fmt.Sprintf("%s: quoted value is never closed", key), false})
+		}
+
+		switch {
+		case entry.Encrypted:
+			data := entry.Value
+			if idx := strings.LastIndex(data, ",type:"); idx != -1 {
+				data = data[:idx]
+			}
+			if !slices.Contains(valueAlgorithms, entry.Algorithm) {
+				diags = append(diags, Diagnostic{n, SeverityError, fmt.Sprintf("%s: unknown algorithm ENC[%s:...]", key, entry.Algorithm), false})
+			} else if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+				diags = append(diags, Diagnostic{n, SeverityError, fmt.Sprintf("%s: corrupt base64 in encrypted value", key), false})
+			}
+
+			kind := "password"
+			if entry.Algorithm == "age" {
+				kind = "age"
+			}
+			if _, ok := algorithms[kind]; !ok {
+				algorithms[kind] = n
+			}
+		case IsEncryptedValue(strings.TrimSpace(entry.Value)):
+			diags = append(diags, Diagnostic{n, SeverityError, fmt.Sprintf("%s: malformed ENC[...] value", key), false})
+		case encrypted && UnquoteValue(entry.Value) != "" && !IsSecretRef(entry.Value):
+			diags = append(diags, Diagnostic{n, SeverityWarning, fmt.Sprintf("%s: value is not encrypted", key), false})
+		}
+	}
+
+	if len(algorithms) > 1 {
+		diags = append(diags, Diagnostic{algorithms["password"], SeverityWarning,
+			fmt.Sprintf("password-encrypted values mixed with age values (first age value on line %d)", algorithms["age"]), false})
+		slices.SortStableFunc(diags, func(a, b Diagnostic) int { return a.Line - b.Line })
+	}
+
+	return diags
+}
+
+// FixEnv memperbaiki temuan yang aman diperbaiki tanpa mengubah hasil di
+// runtime: key duplikat yang tertimpa dihapus, dan baris tanpa '=' diberi
+// '#' supaya jelas merupakan komentar. Baris lanjutan nilai multi-line
+// dalam tanda kutip tidak disentuh. Mengembalikan jumlah perbaikan.
+func FixEnv(data []byte) ([]byte, int) {
+	entries := envEntries(envLines(data))
+
+	lastLine := make(map[string]int)
+	for _, e := range entries {
+		if !e.Entry.IsComment {
+			lastLine[envKey(e.Entry.Key)] = e.Line
+		}
+	}
+
+	var out []string
+	fixed := 0
+	for _, e := range entries {
+		trimmed := strings.TrimSpace(e.Entry.Raw)
+
+		switch {
+		case e.Entry.IsComment && trimmed != "" && !strings.HasPrefix(trimmed, "#"):
+			out = append(out, "# "+e.Entry.Raw)
+			fixed++
+		case !e.Entry.IsComment && lastLine[envKey(e.Entry.Key)] != e.Line:
+			// Baris lanjutan nilai multi-line ikut dihapus
+			fixed++
+		default:
+			out = append(out, e.Lines...)
+		}
+	}
+
+	// Pertahankan line ending asli
+	eol := "\n"
+	if strings.Contains(string(data), "\r\n") {
+		eol = "\r\n"
+	}
+	result := strings.Join(out, eol)
+	if strings.HasSuffix(string(data), "\n") {
+		result += eol
+	}
+	return []byte(result), fixed
+}
+
+// envEntry adalah satu entri logis di file .env: baris KEY=VALUE beserta
+// baris lanjutan kalau nilainya dalam tanda kutip dan melewati beberapa baris
+type envEntry struct {
+	Line         int      // Index baris pertama
+	Lines        []string // Baris asli, termasuk baris lanjutan
+	Entry        EnvEntry // Hasil parseLine untuk baris pertama
+	Unterminated bool     // Tanda kutip tidak pernah ditutup sampai akhir file
+}
+
+// envEntries mengelompokkan baris menjadi entri logis, supaya baris lanjutan
+// nilai multi-line tidak dianggap baris tanpa '='
+func envEntries(lines []string) []envEntry {
+	var entries []envEntry
+	for i := 0; i < len(lines); i++ {
+		e := envEntry{Line: i, Lines: lines[i : i+1], Entry: parseLine(lines[i])}
+
+		value := strings.TrimSpace(e.Entry.Value)
+		if !e.Entry.IsComment && !e.Entry.Encrypted && value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			if closingQuote(value[1:], quote) == -1 {
+				end := i + 1
+				for end < len(lines) && closingQuote(lines[end], quote) == -1 {
+					end++
+				}
+				if end == len(lines) {
+					e.Unterminated = true
+					end--
+				}
+				e.Lines = lines[i : end+1]
+				i = end
+			}
+		}
+
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// closingQuote mengembalikan index tanda kutip penutup di s, atau -1. Di
+// dalam "..." karakter setelah '\' di-escape.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// envLines memecah data menjadi baris tanpa line ending
+func envLines(data []byte) []string {
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func envKey(key string) string {
+	return strings.TrimSpace(strings.TrimPrefix(key, "export "))
+}
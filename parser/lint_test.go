@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func diagStrings(diags []Diagnostic) []string {
+	var out []string
+	for _, d := range diags {
+		out = append(out, d.String())
+	}
+	return out
+}
+
+func TestLintEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		encrypted bool
+		want      []string
+	}{
+		{
+			name:  "clean",
+			input: "# comment\n\nA=1\nexport B=\"two\"\n",
+		},
+		{
+			name:  "duplicates and malformed lines",
+			input: "A=1\nnot a pair\nexport A=2\n1BAD=x\n",
+			want: []string{
+				"1: warning: duplicate key 'A' is overridden on line 3 (fixable)",
+				"2: error: line is not KEY=VALUE and is ignored as a comment (fixable)",
+				"4: error: invalid key name '1BAD'",
+			},
+		},
+		{
+			name:  "multi-line quoted values",
+			input: "CERT=\"-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\"\nNOTE='first\nsecond'\nESCAPED=\"a \\\" still open\nclosed\"\nB=2\n",
+		},
+		{
+			name:  "unterminated quote",
+			input: "A=1\nB=\"open\nC=3\n",
+			want:  []string{"2: error: B: quoted value is never closed"},
+		},
+		{
+			name:  "encrypted values",
+			input: "A=ENC[age:YWJj]\nB=ENC[rot13:YWJj]\nC=ENC[age:not base64!]\nD=ENC[age]\n",
+			want: []string{
+				"2: error: B: unknown algorithm ENC[rot13:...]",
+				"2: warning: password-encrypted values mixed with age values (first age value on line 1)",
+				"3: error: C: corrupt base64 in encrypted value",
+				"4: error: D: malformed ENC[...] value",
+			},
+		},
+		{
+			name:  "typed values",
+			input: "A=ENC[age:YWJj,type:int]\n",
+		},
+		{
+			name:  "age mixed with password",
+			input: "A=ENC[age:YWJj]\nB=ENC[aes-gcm:YWJj]\n",
+			want:  []string{"2: warning: password-encrypted values mixed with age values (first age value on line 1)"},
+		},
+		{
+			name:      "plaintext in an encrypted file",
+			input:     "A=ENC[age:YWJj]\nB=plain\nC=\nD=podx://shared/.env#D\n",
+			encrypted: true,
+			want:      []string{"2: warning: B: value is not encrypted"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diagStrings(LintEnv([]byte(tt.input), tt.encrypted))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestFixEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		fixed int
+	}{
+		{
+			name:  "nothing to fix",
+			input: "# comment\nA=1\n",
+			want:  "# comment\nA=1\n",
+		},
+		{
+			name:  "duplicates keep the last value",
+			input: "A=1\nB=2\nexport A=3\nA = 4\n",
+			want:  "B=2\nA = 4\n",
+			fixed: 2,
+		},
+		{
+			name:  "malformed lines become comments",
+			input: "A=1\nnot a pair\n  indented\n",
+			want:  "A=1\n# not a pair\n#   indented\n",
+			fixed: 2,
+		},
+		{
+			name:  "multi-line values are kept whole",
+			input: "KEY=\"-----BEGIN KEY-----\nabc def\n-----END KEY-----\"\nNOTE='a\nb c'\n",
+			want:  "KEY=\"-----BEGIN KEY-----\nabc def\n-----END KEY-----\"\nNOTE='a\nb c'\n",
+		},
+		{
+			name:  "duplicate multi-line values are removed whole",
+			input: "KEY=\"old\nvalue here\"\nKEY=\"new\nvalue\"\n",
+			want:  "KEY=\"new\nvalue\"\n",
+			fixed: 1,
+		},
+		{
+			name:  "unterminated quote is left alone",
+			input: "A=\"open\nnot a pair\n",
+			want:  "A=\"open\nnot a pair\n",
+		},
+		{
+			name:  "CRLF and no trailing newline",
+			input: "A=1\r\nA=2\r\nstray",
+			want:  "A=2\r\n# stray",
+			fixed: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fixed := FixEnv([]byte(tt.input))
+			if string(got) != tt.want || fixed != tt.fixed {
+				t.Errorf("got %q (%d fixes), want %q (%d fixes)", got, fixed, tt.want, tt.fixed)
+			}
+			// Fixed output has nothing fixable left
+			for _, d := range LintEnv(got, false) {
+				if d.Fixable {
+					t.Errorf("still fixable after FixEnv: %s", d)
+				}
+			}
+		})
+	}
+}
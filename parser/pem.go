@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// PEMEncryptedBlockType adalah tipe blok PEM untuk private key yang dienkripsi
+const PEMEncryptedBlockType = "PODX ENCRYPTED BLOCK"
+
+// publicPEMBlocks adalah tipe blok yang tidak rahasia. Blok lain (private
+// key, PGP PRIVATE KEY BLOCK, OpenVPN Static key, tipe yang tidak dikenal)
+// selalu dienkripsi.
+var publicPEMBlocks = map[string]bool{
+	"CERTIFICATE":             true,
+	"TRUSTED CERTIFICATE":     true,
+	"CERTIFICATE REQUEST":     true,
+	"NEW CERTIFICATE REQUEST": true,
+	"X509 CRL":                true,
+	"PUBLIC KEY":              true,
+}
+
+// pemCodec mengenkripsi semua blok PEM kecuali blok publik seperti
+// CERTIFICATE, yang tetap terbaca supaya reviewer bisa melihat perubahan
+// sertifikat. Blok asli dienkripsi utuh, jadi dekripsi mengembalikan file
+// yang sama persis.
+type pemCodec struct{}
+
+func (pemCodec) Encrypt(data []byte, c ValueCipher) ([]byte, error) {
+	// Jangan sampai file tanpa blok PEM ditulis ulang sebagai plaintext
+	if !strings.Contains(string(data), "-----BEGIN ") {
+		return nil, fmt.Errorf("no PEM blocks found (use format: binary)")
+	}
+
+	return mapPEMBlocks(data, func(blockType, block string) (string, error) {
+		if publicPEMBlocks[blockType] {
+			return block, nil
+		}
+
+		algo, ciphertext, err := c.Seal([]byte(block))
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt %s block: %w", blockType, err)
+		}
+
+		encoded := pem.EncodeToMemory(&pem.Block{
+			Type:    PEMEncryptedBlockType,
+			Headers: map[string]string{"Algorithm": algo},
+			Bytes:   ciphertext,
+		})
+		return strings.TrimSuffix(string(encoded), "\n"), nil
+	})
+}
+
+func (pemCodec) Decrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return mapPEMBlocks(data, func(blockType, block string) (string, error) {
+		if blockType != PEMEncryptedBlockType {
+			return block, nil
+		}
+
+		decoded, _ := pem.Decode([]byte(block))
+		if decoded == nil {
+			return "", fmt.Errorf("invalid %s", PEMEncryptedBlockType)
+		}
+
+		plaintext, err := c.Open(decoded.Headers["Algorithm"], decoded.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt PEM block: %w", err)
+		}
+		return string(plaintext), nil
+	})
+}
+
+// mapPEMBlocks mengganti setiap blok -----BEGIN X----- ... -----END X-----
+// dengan hasil fn. Teks di luar blok disalin apa adanya.
+func mapPEMBlocks(data []byte, fn func(blockType, block string) (string, error)) ([]byte, error) {
+	text := string(data)
+	var sb strings.Builder
+
+	for {
+		start := strings.Index(text, "-----BEGIN ")
+		if start == -1 {
+			break
+		}
+
+		// Header harus lengkap di satu baris; header yang terpotong tidak
+		// boleh membuat sisa file lolos sebagai plaintext
+		header := text[start:]
+		if nl := strings.IndexByte(header, '\n'); nl != -1 {
+			header = header[:nl]
+		}
+		header = strings.TrimSuffix(header, "\r")
+		if len(header) <= len("-----BEGIN -----") || !strings.HasSuffix(header, "-----") {
+			line := strings.Count(string(data[:len(data)-len(text)+start]), "\n") + 1
+			return nil, fmt.Errorf("truncated PEM header on line %d", line)
+		}
+		blockType := header[len("-----BEGIN ") : len(header)-len("-----")]
+
+		endMarker := "-----END " + blockType + "-----"
+		end := strings.Index(text[start:], endMarker)
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated PEM block %s", blockType)
+		}
+		end += start + len(endMarker)
+
+		replacement, err := fn(blockType, text[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		sb.WriteString(text[:start])
+		sb.WriteString(replacement)
+		text = text[end:]
+	}
+
+	sb.WriteString(text)
+	return []byte(sb.String()), nil
+}
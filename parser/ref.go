@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SecretRef adalah referensi ke key di file terenkripsi lain dalam project,
+// ditulis sebagai podx://shared/.env.podx#REDIS_PASSWORD (atau dengan
+// prefix ref+). Path relatif terhadap root project.
+type SecretRef struct {
+	Path string
+	Key  string
+}
+
+func (r SecretRef) String() string {
+	return "podx://" + r.Path + "#" + r.Key
+}
+
+// ParseSecretRef mem-parse nilai (sudah di-unquote) sebagai SecretRef.
+// ok false berarti nilai bukan referensi; error berarti referensi rusak.
+func ParseSecretRef(value string) (ref SecretRef, ok bool, err error) {
+	rest, found := strings.CutPrefix(value, "ref+podx://")
+	if !found {
+		if rest, found = strings.CutPrefix(value, "podx://"); !found {
+			return SecretRef{}, false, nil
+		}
+	}
+
+	path, key, _ := strings.Cut(rest, "#")
+	if path == "" || key == "" {
+		return SecretRef{}, true, fmt.Errorf("invalid reference '%s' (expected podx://path#KEY)", value)
+	}
+	return SecretRef{Path: path, Key: key}, true, nil
+}
+
+// IsSecretRef mengecek apakah nilai mentah di file .env adalah referensi
+func IsSecretRef(value string) bool {
+	_, ok, _ := ParseSecretRef(UnquoteValue(value))
+	return ok
+}
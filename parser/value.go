@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/hades/podx/crypto"
+)
+
+// ValueCipher mengenkripsi dan mendekripsi satu nilai.
+// Dipakai oleh semua codec format-preserving (ENC[algo:base64]).
+type ValueCipher interface {
+	// Seal mengenkripsi plaintext, mengembalikan nama algoritma dan ciphertext
+	Seal(plaintext []byte) (algo string, ciphertext []byte, err error)
+	// Open mendekripsi ciphertext yang dienkripsi dengan algoritma algo
+	Open(algo string, ciphertext []byte) ([]byte, error)
+}
+
+// EncryptValue mengenkripsi plaintext ke format ENC[algo:base64]
+func EncryptValue(c ValueCipher, plaintext []byte) (string, error) {
+	return EncryptTypedValue(c, plaintext, "")
+}
+
+// EncryptTypedValue seperti EncryptValue, tapi juga mencatat type asli nilai
+// (int, float, bool, ...) supaya bisa dikembalikan saat dekripsi:
+// ENC[algo:base64,type:int]. Type kosong berarti string.
+func EncryptTypedValue(c ValueCipher, plaintext []byte, typ string) (string, error) {
+	algo, ciphertext, err := c.Seal(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(ciphertext)
+	if typ != "" && typ != "str" {
+		return fmt.Sprintf("ENC[%s:%s,type:%s]", algo, encoded, typ), nil
+	}
+	return fmt.Sprintf("ENC[%s:%s]", algo, encoded), nil
+}
+
+// DecryptValue mendekripsi nilai dengan format ENC[algo:base64]
+func DecryptValue(c ValueCipher, value string) ([]byte, error) {
+	plaintext, _, err := DecryptTypedValue(c, value)
+	return plaintext, err
+}
+
+// DecryptTypedValue mendekripsi nilai ENC[...] dan mengembalikan type aslinya
+// ("str" jika tidak dicatat)
+func DecryptTypedValue(c ValueCipher, value string) ([]byte, string, error) {
+	matches := encryptedValuePattern.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, "", fmt.Errorf("not an encrypted value")
+	}
+
+	data, typ := matches[2], "str"
+	if idx := strings.LastIndex(data, ",type:"); idx != -1 {
+		data, typ = data[:idx], data[idx+len(",type:"):]
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid base64: %w", err)
+	}
+
+	plaintext, err := c.Open(matches[1], ciphertext)
+	if err != nil {
+		return nil, "", err
+	}
+	return plaintext, typ, nil
+}
+
+// IsEncryptedValue mengecek apakah value sudah dalam format ENC[...]
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, "ENC[")
+}
+
+// AgeCipher mengenkripsi nilai untuk Age recipients dan mendekripsi dengan identity
+type AgeCipher struct {
+	Recipients []string
+	Identity   string
+}
+
+func (a *AgeCipher) Seal(plaintext []byte) (string, []byte, error) {
+	ciphertext, err := crypto.AgeEncrypt(plaintext, a.Recipients...)
+	if err != nil {
+		return "", nil, err
+	}
+	return "age", ciphertext, nil
+}
+
+func (a *AgeCipher) Open(algo string, ciphertext []byte) ([]byte, error) {
+	if algo != "age" {
+		return nil, fmt.Errorf("unsupported algorithm '%s' (expected age)", algo)
+	}
+	return crypto.AgeDecrypt(ciphertext, a.Identity)
+}
+
+// PasswordCipher mengenkripsi nilai dengan key turunan password (aes-gcm / chacha20)
+type PasswordCipher struct {
+	Key       []byte
+	Algorithm crypto.Algorithm
+}
+
+func (p *PasswordCipher) Seal(plaintext []byte) (string, []byte, error) {
+	enc, err := crypto.NewEncryptor(p.Algorithm)
+	if err != nil {
+		return "", nil, err
+	}
+	ciphertext, err := enc.Encrypt(plaintext, p.Key)
+	if err != nil {
+		return "", nil, err
+	}
+	return enc.Name(), ciphertext, nil
+}
+
+func (p *PasswordCipher) Open(algo string, ciphertext []byte) ([]byte, error) {
+	enc, err := crypto.NewEncryptor(crypto.Algorithm(algo))
+	if err != nil {
+		return nil, err
+	}
+	return enc.Decrypt(ciphertext, p.Key)
+}
@@ -0,0 +1,365 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlCodec mengenkripsi nilai scalar di file YAML menjadi ENC[...].
+// Key, struktur, komentar dan anchor tetap terbaca. Untuk manifest
+// Kubernetes Secret hanya nilai di bawah data/stringData yang dienkripsi.
+type yamlCodec struct{}
+
+// yamlLeaf adalah satu scalar leaf yang akan dienkripsi/didekripsi
+type yamlLeaf struct {
+	Path   []string
+	Node   *yaml.Node
+	Base64 bool // Nilai data: di Kubernetes Secret (dienkripsi dalam bentuk ter-decode)
+	Flow   bool // Di dalam flow collection ([a, b] atau {a: b})
+}
+
+// plainStrMarker menandai string plain di flow collection. ENC[...] di sana
+// harus diberi quote oleh yaml.v3, jadi tanpa penanda string plain dan
+// string ber-quote tidak bisa dibedakan saat dekripsi.
+const plainStrMarker = ",type:str]"
+
+func (yamlCodec) Encrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return mapYAMLScalars(data, func(leaf yamlLeaf) error {
+		node := leaf.Node
+
+		// null tidak perlu dienkripsi, dan nilai yang sudah terenkripsi di-skip
+		if node.Tag == "!!null" || IsEncryptedValue(node.Value) {
+			return nil
+		}
+
+		plaintext := []byte(node.Value)
+		if leaf.Base64 {
+			decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(node.Value), ""))
+			if err != nil {
+				return fmt.Errorf("invalid base64 in '%s': %w", strings.Join(leaf.Path, "."), err)
+			}
+			plaintext = decoded
+		}
+
+		encValue, err := EncryptTypedValue(c, plaintext, yamlType(node.Tag))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt key '%s': %w", strings.Join(leaf.Path, "."), err)
+		}
+		if leaf.Flow && node.Style == 0 && yamlType(node.Tag) == "" {
+			encValue = strings.TrimSuffix(encValue, "]") + plainStrMarker
+		}
+
+		// Style (quoted, literal, ...) dipertahankan supaya kembali sama saat dekripsi
+		node.Value = encValue
+		node.Tag = "!!str"
+		return nil
+	})
+}
+
+func (yamlCodec) Decrypt(data []byte, c ValueCipher) ([]byte, error) {
+	return mapYAMLScalars(data, func(leaf yamlLeaf) error {
+		node := leaf.Node
+		if !encryptedValuePattern.MatchString(node.Value) {
+			return nil
+		}
+
+		plaintext, typ, err := DecryptTypedValue(c, node.Value)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt key '%s': %w", strings.Join(leaf.Path, "."), err)
+		}
+
+		if leaf.Base64 {
+			plaintext = []byte(base64.StdEncoding.EncodeToString(plaintext))
+		}
+
+		// Nilai non-string dan string plain di flow collection ditulis tanpa
+		// quote yang ditambahkan yaml.v3 untuk ENC[...]
+		if typ != "str" || (leaf.Flow && strings.HasSuffix(node.Value, plainStrMarker)) {
+			node.Style &^= yaml.SingleQuotedStyle | yaml.DoubleQuotedStyle
+		}
+		node.Value = string(plaintext)
+		node.Tag = "!!" + typ
+		return nil
+	})
+}
+
+// yamlType mengubah tag YAML (!!int, !!bool, ...) menjadi nama type ENC
+func yamlType(tag string) string {
+	if strings.HasPrefix(tag, "!!") && tag != "!!str" {
+		return strings.TrimPrefix(tag, "!!")
+	}
+	return ""
+}
+
+// mapYAMLScalars memanggil fn untuk setiap scalar leaf (bukan key) di semua
+// dokumen, lalu menulis ulang YAML dengan indentasi yang sama
+func mapYAMLScalars(data []byte, fn func(leaf yamlLeaf) error) ([]byte, error) {
+	docs, err := decodeYAMLDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Di file manifest Kubernetes, dokumen selain Secret (Deployment,
+	// ConfigMap, ...) dibiarkan plaintext
+	manifest := false
+	for _, doc := range docs {
+		manifest = manifest || IsK8sSecret(doc)
+	}
+
+	for _, doc := range docs {
+		secret := IsK8sSecret(doc)
+		if manifest && !secret {
+			continue
+		}
+
+		err := walkYAML(doc, nil, false, func(path []string, node *yaml.Node, flow bool) error {
+			if !secret {
+				return fn(yamlLeaf{Path: path, Node: node, Flow: flow})
+			}
+
+			// Kubernetes Secret: metadata dan field lain tetap plaintext
+			if len(path) != 2 || (path[0] != "data" && path[0] != "stringData") {
+				return nil
+			}
+			return fn(yamlLeaf{Path: path, Node: node, Base64: path[0] == "data", Flow: flow})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := encodeYAMLDocuments(docs, detectIndent(data))
+	if err != nil {
+		return nil, err
+	}
+	return restoreBlankLines(data, out), nil
+}
+
+// IsK8sSecret mengecek apakah dokumen YAML adalah manifest Kubernetes Secret
+func IsK8sSecret(doc *yaml.Node) bool {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return false
+	}
+
+	kind, apiVersion := "", ""
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		switch doc.Content[i].Value {
+		case "kind":
+			kind = doc.Content[i+1].Value
+		case "apiVersion":
+			apiVersion = doc.Content[i+1].Value
+		}
+	}
+	return kind == "Secret" && apiVersion == "v1"
+}
+
+// decodeYAMLDocuments mem-parse semua dokumen (dipisah ---) di data
+func decodeYAMLDocuments(data []byte) ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		docs = append(docs, &doc)
+	}
+
+	return docs, nil
+}
+
+func encodeYAMLDocuments(docs []*yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to write YAML: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write YAML: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func walkYAML(node *yaml.Node, path []string, flow bool, fn func(path []string, node *yaml.Node, flow bool) error) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := walkYAML(child, path, flow, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		flow = flow || node.Style&yaml.FlowStyle != 0
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			// yaml.v3 menulis merge key sebagai "!!merge <<" kalau tag-nya tidak dikosongkan
+			if node.Content[i].Tag == "!!merge" {
+				node.Content[i].Tag = ""
+			}
+
+			key := node.Content[i].Value
+			if err := walkYAML(node.Content[i+1], append(path, key), flow, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		flow = flow || node.Style&yaml.FlowStyle != 0
+		for i, child := range node.Content {
+			if err := walkYAML(child, append(path, fmt.Sprint(i)), flow, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		return fn(path, node, flow)
+	}
+	// AliasNode menunjuk ke anchor yang sudah diproses
+	return nil
+}
+
+// yamlNode adalah posisi satu node di file
+type yamlNode struct {
+	Line  int  // 0-based
+	Value bool // Scalar yang bukan key
+}
+
+// yamlNodeLines mengembalikan posisi semua node (pre-order, jadi urut
+// sesuai file). Dua file dengan struktur yang sama menghasilkan daftar
+// yang sejajar.
+func yamlNodeLines(data []byte) ([]yamlNode, error) {
+	docs, err := decodeYAMLDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []yamlNode
+	var walk func(n *yaml.Node, key bool)
+	walk = func(n *yaml.Node, key bool) {
+		nodes = append(nodes, yamlNode{Line: n.Line - 1, Value: n.Kind == yaml.ScalarNode && !key})
+		for i, child := range n.Content {
+			walk(child, n.Kind == yaml.MappingNode && i%2 == 0)
+		}
+	}
+	for _, doc := range docs {
+		walk(doc, false)
+	}
+	return nodes, nil
+}
+
+// restoreBlankLines menyisipkan kembali baris kosong dari original yang
+// dibuang yaml.v3. Setiap baris kosong ditaruh di depan node berikutnya
+// (dan komentar di atasnya) di out. Baris kosong di dalam nilai multi-line
+// (block scalar |, >) ikut terenkripsi, jadi dilewati.
+func restoreBlankLines(original, out []byte) []byte {
+	origNodes, err := yamlNodeLines(original)
+	if err != nil {
+		return out
+	}
+	outNodes, err := yamlNodeLines(out)
+	if err != nil || len(outNodes) != len(origNodes) {
+		return out
+	}
+
+	lines := strings.Split(string(original), "\n")
+	blank := func(i int) bool { return strings.TrimSpace(lines[i]) == "" }
+	indent := func(i int) int { return len(lines[i]) - len(strings.TrimLeft(lines[i], " ")) }
+
+	outLines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	insert := map[int]int{} // Baris di out -> jumlah baris kosong di depannya
+	trailing := 0
+	for b := 0; b < len(lines)-1; b++ {
+		if !blank(b) {
+			continue
+		}
+
+		// Node terakhir sebelum baris kosong, dan node pertama setelahnya
+		prev, next := -1, -1
+		for j, n := range origNodes {
+			if n.Line < b {
+				prev = j
+			} else if n.Line > b {
+				next = j
+				break
+			}
+		}
+
+		// Baris berikutnya yang lebih menjorok dari awal scalar adalah
+		// lanjutan nilai multi-line
+		following := b + 1
+		for following < len(lines) && blank(following) {
+			following++
+		}
+		if prev >= 0 && origNodes[prev].Value && following < len(lines) && indent(following) > indent(origNodes[prev].Line) {
+			continue
+		}
+
+		if next == -1 {
+			trailing++
+			continue
+		}
+
+		// Komentar di antara baris kosong dan node ikut dipindah
+		comments := 0
+		for i := b + 1; i < origNodes[next].Line; i++ {
+			if !blank(i) {
+				comments++
+			}
+		}
+		at := outNodes[next].Line
+		for at > 0 && comments > 0 {
+			at--
+			if strings.TrimSpace(outLines[at]) != "" {
+				comments--
+			}
+		}
+		insert[at]++
+	}
+
+	var sb strings.Builder
+	for i, line := range outLines {
+		// yaml.v3 kadang mempertahankan baris kosong sendiri
+		n := insert[i]
+		for k := i - 1; k >= 0 && n > 0 && strings.TrimSpace(outLines[k]) == ""; k-- {
+			n--
+		}
+		sb.WriteString(strings.Repeat("\n", n))
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString(strings.Repeat("\n", trailing))
+	return []byte(sb.String())
+}
+
+// detectIndent menebak lebar indentasi file (default 2)
+func detectIndent(data []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if n := len(line) - len(trimmed); n > 0 && (indent == 0 || n < indent) {
+			indent = n
+		}
+	}
+
+	if indent < 2 || indent > 8 {
+		return 2
+	}
+	return indent
+}
@@ -0,0 +1,220 @@
+package project
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hades/podx/parser"
+)
+
+// Matcher selects secret files with gitignore-style patterns.
+//
+// Patterns are relative to the project root and use '/' as separator:
+//   - '*', '?' and '[...]' match within a single path segment
+//   - '**' matches any number of directories (config/**/*.pem)
+//   - a leading '!' excludes files matched by an earlier pattern
+//   - a trailing '/' matches everything below a directory
+//
+// As in .gitignore, the last matching pattern wins.
+type Matcher struct {
+	patterns []matchPattern
+}
+
+type matchPattern struct {
+	index    int // Position in the list passed to NewMatcher
+	negate   bool
+	segments []string
+}
+
+// NewMatcher compiles secret patterns from .podx.yaml
+func NewMatcher(patterns []string) *Matcher {
+	m := &Matcher{}
+	for i, raw := range patterns {
+		p := strings.TrimSpace(raw)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		mp := matchPattern{index: i}
+		if strings.HasPrefix(p, "!") {
+			mp.negate = true
+			p = p[1:]
+		}
+
+		p = strings.TrimPrefix(filepath.ToSlash(p), "/")
+		if strings.HasSuffix(p, "/") {
+			p += "**"
+		}
+		mp.segments = strings.Split(p, "/")
+
+		m.patterns = append(m.patterns, mp)
+	}
+	return m
+}
+
+// Match reports whether relPath (relative to the project root) is a secret
+func (m *Matcher) Match(relPath string) bool {
+	return m.MatchIndex(relPath) >= 0
+}
+
+// MatchIndex returns the index of the pattern that selects relPath, or -1
+// if no pattern matches or the file is excluded
+func (m *Matcher) MatchIndex(relPath string) int {
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+
+	matched := -1
+	for _, p := range m.patterns {
+		if matchSegments(p.segments, segments) {
+			if p.negate {
+				matched = -1
+			} else {
+				matched = p.index
+			}
+		}
+	}
+	return matched
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// '**' pattern segment consumes zero or more path segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// GitignoreLines returns the .gitignore entries that keep decrypted secrets
+// out of git while still allowing the encrypted .podx files to be committed.
+// Entries are anchored to the root like the Matcher's patterns. Git can't
+// re-include files of an ignored directory, so directories are written as
+// dir/** and directories matched by a wildcard are re-included: like the
+// Matcher, the entries only ever ignore files.
+func (m *Matcher) GitignoreLines() []string {
+	var lines []string
+	needsPodxException := false
+
+	for _, p := range m.patterns {
+		line := "/" + strings.Join(p.segments, "/")
+		if p.negate {
+			lines = append(lines, "!"+line)
+			continue
+		}
+		lines = append(lines, line)
+
+		last := p.segments[len(p.segments)-1]
+		if strings.ContainsAny(last, "*?[") {
+			lines = append(lines, "!"+line+"/")
+			needsPodxException = true
+		}
+	}
+
+	// A wildcard like config/* would also ignore config/app.yaml.podx
+	if needsPodxException {
+		lines = append(lines, "!*"+EncryptedExt)
+	}
+
+	return lines
+}
+
+// walkFiles calls fn with the slash-separated path (relative to the project
+// root) of every regular file in the project. Nested projects, .git and
+// node_modules are skipped.
+func (p *Project) walkFiles(fn func(relPath string) error) error {
+	return filepath.WalkDir(p.RootDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path == p.RootDir {
+				return nil
+			}
+			if d.Name() == ".git" || d.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			// Nested projects manage their own secrets
+			if _, err := os.Stat(filepath.Join(path, ConfigFileName)); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(p.RootDir, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(relPath))
+	})
+}
+
+// SecretFiles returns the plaintext secret files matched by Config.Secrets
+func (p *Project) SecretFiles() ([]string, error) {
+	m := NewMatcher(p.Patterns())
+
+	var files []string
+	err := p.walkFiles(func(relPath string) error {
+		if !strings.HasSuffix(relPath, EncryptedExt) && m.Match(relPath) {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// EncryptedFiles returns the .podx files whose decrypted path is matched by
+// Config.Secrets
+func (p *Project) EncryptedFiles() ([]string, error) {
+	m := NewMatcher(p.Patterns())
+
+	var files []string
+	err := p.walkFiles(func(relPath string) error {
+		if strings.HasSuffix(relPath, EncryptedExt) && m.Match(strings.TrimSuffix(relPath, EncryptedExt)) {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// EncryptedEnvFiles returns the encrypted files stored in dotenv format
+func (p *Project) EncryptedEnvFiles() ([]string, error) {
+	files, err := p.EncryptedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var envFiles []string
+	for _, encRel := range files {
+		data, err := os.ReadFile(filepath.Join(p.RootDir, filepath.FromSlash(encRel)))
+		if err != nil {
+			return nil, err
+		}
+		if format, err := p.formatFor(strings.TrimSuffix(encRel, EncryptedExt), data); err == nil && format == parser.FormatDotenv {
+			envFiles = append(envFiles, encRel)
+		}
+	}
+	return envFiles, nil
+}
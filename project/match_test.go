@@ -0,0 +1,87 @@
+package project
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	m := NewMatcher([]string{".env", "secrets/", "!secrets/public.txt", "config/**/*.pem"})
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{".env", true},
+		{"sub/.env", false},
+		{"secrets/a.yaml", true},
+		{"secrets/deep/b.env", true},
+		{"secrets/public.txt", false},
+		{"config/tls.pem", true},
+		{"config/a/b/tls.pem", true},
+		{"config/tls.key", false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.path); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGitignoreLines(t *testing.T) {
+	got := NewMatcher([]string{".env", "secrets/", "!secrets/public.txt"}).GitignoreLines()
+	want := []string{"/.env", "/secrets/**", "!/secrets/**/", "!/secrets/public.txt", "!*.podx"}
+	if !slices.Equal(got, want) {
+		t.Errorf("GitignoreLines() = %q, want %q", got, want)
+	}
+}
+
+// TestGitignoreAgreesWithMatcher checks with git itself that the generated
+// .gitignore ignores exactly the files the Matcher selects, and never the
+// encrypted .podx files
+func TestGitignoreAgreesWithMatcher(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	patterns := []string{".env", "secrets/", "!secrets/public.txt", "config/**/*.pem", "*.key", "certs/*"}
+	m := NewMatcher(patterns)
+
+	dir := t.TempDir()
+	files := []string{
+		".env", ".env.podx", "sub/.env",
+		"secrets/a.yaml", "secrets/a.yaml.podx", "secrets/deep/b.env", "secrets/deep/b.env.podx", "secrets/public.txt",
+		"config/tls.pem", "config/a/tls.pem", "config/a/tls.pem.podx", "config/tls.crt",
+		"ta.key", "ta.key.podx",
+		"certs/a.pem", "certs/a.pem.podx", "certs/old/b.pem", "certs/old/b.pem.podx",
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitignore := strings.Join(m.GitignoreLines(), "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(gitignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	for _, f := range files {
+		err := exec.Command("git", "-C", dir, "check-ignore", "-q", f).Run()
+		ignored := err == nil
+		want := !strings.HasSuffix(f, EncryptedExt) && m.Match(f)
+		if ignored != want {
+			t.Errorf("%s: ignored by git = %v, matched = %v\n.gitignore:\n%s", f, ignored, want, gitignore)
+		}
+	}
+}
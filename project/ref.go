@@ -0,0 +1,291 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hades/podx/keygen"
+	"github.com/hades/podx/parser"
+)
+
+// refResolver resolves podx:// references, decrypting each referenced file once
+type refResolver struct {
+	p        *Project
+	identity string
+	files    map[string]map[string]string // Decrypted values per file, references unresolved
+	resolved map[string]string
+	visiting map[string]bool
+	previous map[string]map[string]string // References per .env.podx before encrypting, see loadPreviousRefs
+}
+
+// ResolveRefs replaces podx://path#KEY values in vars with the value of KEY
+// in the referenced encrypted file. Paths are relative to the project root;
+// references may point at further references, and cycles are errors.
+func (p *Project) ResolveRefs(vars []parser.EnvVar) ([]parser.EnvVar, error) {
+	r := p.newRefResolver("")
+
+	result := make([]parser.EnvVar, len(vars))
+	for i, v := range vars {
+		result[i] = v
+
+		ref, ok, err := parser.ParseSecretRef(v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v.Key, err)
+		}
+		if !ok {
+			continue
+		}
+
+		value, err := r.resolve(ref, []string{v.Key})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v.Key, err)
+		}
+		result[i].Value = value
+	}
+
+	return result, nil
+}
+
+// newRefResolver returns a resolver that decrypts with identity, or loads
+// the user's identity when the first encrypted file is read
+func (p *Project) newRefResolver(identity string) *refResolver {
+	return &refResolver{
+		p:        p,
+		identity: identity,
+		files:    make(map[string]map[string]string),
+		resolved: make(map[string]string),
+		visiting: make(map[string]bool),
+		previous: make(map[string]map[string]string),
+	}
+}
+
+// resolveEnvRefs replaces the references in a decrypted .env file with
+// the values they point to, leaving every other line as it is
+func (p *Project) resolveEnvRefs(data []byte, identity string) ([]byte, error) {
+	r := p.newRefResolver(identity)
+	return parser.MapEnvValues(data, func(key, value string) (string, error) {
+		ref, ok, err := parser.ParseSecretRef(parser.UnquoteValue(value))
+		if !ok {
+			return value, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+
+		resolved, err := r.resolve(ref, []string{key})
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		return parser.QuoteValue(resolved) + lineEnding(value), nil
+	})
+}
+
+// loadPreviousRefs reads the references in the encrypted .env file at
+// encPath and resolves them, so restoreEnvRefs compares with the values
+// they had before any file of this run was rewritten. Targets that can't be
+// read are skipped; restoreEnvRefs then keeps the plaintext value.
+func (r *refResolver) loadPreviousRefs(encPath string) {
+	entries, err := parser.ParseEnvFile(encPath)
+	if err != nil {
+		return
+	}
+	refs := make(map[string]string) // Key -> reference as written
+	for _, v := range parser.EnvVars(entries) {
+		if ref, ok, err := parser.ParseSecretRef(v.Value); ok && err == nil {
+			refs[v.Key] = v.Value
+			r.resolve(ref, []string{v.Key})
+		}
+	}
+	r.previous[encPath] = refs
+}
+
+// restoreEnvRefs undoes resolveEnvRefs before a .env file is encrypted
+// again: a value that still equals what its reference in the previous
+// encrypted file (encPath, see loadPreviousRefs) resolved to is written
+// back as the reference, so the shared secret isn't copied into the file.
+// Changed values are encrypted as they are.
+func (r *refResolver) restoreEnvRefs(data []byte, encPath string) ([]byte, error) {
+	refs := r.previous[encPath]
+	if len(refs) == 0 {
+		return data, nil
+	}
+
+	return parser.MapEnvValues(data, func(key, value string) (string, error) {
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		raw, ok := refs[key]
+		if !ok || parser.IsSecretRef(value) {
+			return value, nil
+		}
+
+		ref, _, _ := parser.ParseSecretRef(raw)
+		if resolved, ok := r.resolved[refTarget(ref.Path)+"#"+ref.Key]; ok && resolved == parser.UnquoteValue(value) {
+			return raw + lineEnding(value), nil
+		}
+		fmt.Printf("⚠️  %s no longer matches %s, encrypting the new value\n", key, raw)
+		return value, nil
+	})
+}
+
+// lineEnding returns the \r a raw CRLF value ends with
+func lineEnding(value string) string {
+	if strings.HasSuffix(value, "\r") {
+		return "\r"
+	}
+	return ""
+}
+
+func (r *refResolver) resolve(ref parser.SecretRef, chain []string) (string, error) {
+	id := refTarget(ref.Path) + "#" + ref.Key
+	if value, ok := r.resolved[id]; ok {
+		return value, nil
+	}
+
+	chain = append(chain, ref.String())
+	if r.visiting[id] {
+		return "", fmt.Errorf("circular reference: %s", strings.Join(chain, " -> "))
+	}
+
+	values, err := r.load(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("dangling reference %s: key not found", ref)
+	}
+
+	next, isRef, err := parser.ParseSecretRef(value)
+	if err != nil {
+		return "", err
+	}
+	if isRef {
+		r.visiting[id] = true
+		value, err = r.resolve(next, chain)
+		delete(r.visiting, id)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	r.resolved[id] = value
+	return value, nil
+}
+
+// load decrypts the .env file a reference points to
+func (r *refResolver) load(path string) (map[string]string, error) {
+	target := refTarget(path)
+	if values, ok := r.files[target]; ok {
+		return values, nil
+	}
+
+	data, err := r.p.readRefTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parser.ParseEnv(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", target, err)
+	}
+	for _, entry := range entries {
+		if entry.Encrypted && r.identity == "" {
+			if r.identity, err = keygen.LoadAgeIdentity(r.p.IdentityProviders()...); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if err := parser.DecryptAgeValues(entries, r.identity); err != nil {
+		return nil, fmt.Errorf("%s: %w", target, err)
+	}
+
+	values := make(map[string]string)
+	for _, v := range parser.EnvVars(entries) {
+		values[v.Key] = v.Value
+	}
+	r.files[target] = values
+	return values, nil
+}
+
+// refTarget returns the encrypted file a reference path names; podx://.env#KEY
+// and podx://.env.podx#KEY both read .env.podx
+func refTarget(path string) string {
+	path = filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+	if !strings.HasSuffix(path, EncryptedExt) {
+		path += EncryptedExt
+	}
+	return path
+}
+
+func (p *Project) readRefTarget(target string) ([]byte, error) {
+	if filepath.IsAbs(target) || target == ".." || strings.HasPrefix(target, "../") {
+		return nil, fmt.Errorf("reference %s points outside the project", target)
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.RootDir, filepath.FromSlash(target)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("dangling reference: %s does not exist", target)
+	}
+	return data, err
+}
+
+// DanglingRefs lists references in encrypted .env files whose target file or
+// key does not exist. Keys are readable in .podx files, so nothing is decrypted.
+func (p *Project) DanglingRefs() ([]string, error) {
+	files, err := p.EncryptedEnvFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]map[string]bool) // Keys per target file, nil if missing
+	keysOf := func(target string) map[string]bool {
+		if k, ok := keys[target]; ok {
+			return k
+		}
+		keys[target] = nil
+		data, err := p.readRefTarget(target)
+		if err != nil {
+			return nil
+		}
+		entries, err := parser.ParseEnv(data)
+		if err != nil {
+			return nil
+		}
+		k := make(map[string]bool)
+		for _, v := range parser.EnvVars(entries) {
+			k[v.Key] = true
+		}
+		keys[target] = k
+		return k
+	}
+
+	var dangling []string
+	for _, encRel := range files {
+		data, err := os.ReadFile(filepath.Join(p.RootDir, filepath.FromSlash(encRel)))
+		if err != nil {
+			return nil, err
+		}
+		entries, err := parser.ParseEnv(data)
+		if err != nil {
+			continue
+		}
+
+		for _, v := range parser.EnvVars(entries) {
+			ref, ok, err := parser.ParseSecretRef(v.Value)
+			switch {
+			case !ok:
+				continue
+			case err != nil:
+				dangling = append(dangling, fmt.Sprintf("%s: %s (%v)", encRel, v.Key, err))
+			case keysOf(refTarget(ref.Path)) == nil:
+				dangling = append(dangling, fmt.Sprintf("%s: %s → %s (file not found)", encRel, v.Key, ref))
+			case !keysOf(refTarget(ref.Path))[ref.Key]:
+				dangling = append(dangling, fmt.Sprintf("%s: %s → %s (key not found)", encRel, v.Key, ref))
+			}
+		}
+	}
+
+	return dangling, nil
+}
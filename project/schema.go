@@ -0,0 +1,213 @@
+package project
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hades/podx/parser"
+)
+
+// KeySchema describes the expected shape of one .env key
+type KeySchema struct {
+	Description string   `yaml:"description,omitempty"`
+	Required    bool     `yaml:"required,omitempty"`
+	Type        string   `yaml:"type,omitempty"`    // string (default), int, bool, url
+	Pattern     string   `yaml:"pattern,omitempty"` // Regular expression the whole value must match
+	Enum        []string `yaml:"enum,omitempty"`    // Allowed values
+	Default     string   `yaml:"default,omitempty"` // Placeholder in .env.example
+}
+
+// boolValues are the spellings accepted for type: bool
+var boolValues = []string{"true", "false", "1", "0", "yes", "no", "on", "off"}
+
+// Validate checks a value against the schema. Errors never include the
+// value itself, so they are safe to print in CI logs.
+func (s KeySchema) Validate(value string) error {
+	switch strings.ToLower(s.Type) {
+	case "", "string":
+	case "int":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+	case "bool":
+		if !slices.Contains(boolValues, strings.ToLower(value)) {
+			return fmt.Errorf("expected a boolean (%s)", strings.Join(boolValues, ", "))
+		}
+	case "url":
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			return fmt.Errorf("expected a URL with scheme and host")
+		}
+	default:
+		return fmt.Errorf("unknown type '%s' in schema (supported: string, int, bool, url)", s.Type)
+	}
+
+	if s.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + s.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern in schema: %w", err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("does not match pattern %s", s.Pattern)
+		}
+	}
+
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+		return fmt.Errorf("must be one of: %s", strings.Join(s.Enum, ", "))
+	}
+
+	return nil
+}
+
+// Placeholder returns the example value written to .env.example
+func (s KeySchema) Placeholder() string {
+	switch {
+	case s.Default != "":
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	}
+
+	switch strings.ToLower(s.Type) {
+	case "int":
+		return "0"
+	case "bool":
+		return "false"
+	case "url":
+		return "https://example.com"
+	}
+	return ""
+}
+
+// comment describes the key for .env.example, e.g. "Postgres DSN (required, url)"
+func (s KeySchema) comment() string {
+	var attrs []string
+	if s.Required {
+		attrs = append(attrs, "required")
+	}
+	if s.Type != "" && s.Type != "string" {
+		attrs = append(attrs, s.Type)
+	}
+	if len(s.Enum) > 0 {
+		attrs = append(attrs, "one of: "+strings.Join(s.Enum, ", "))
+	}
+	if s.Pattern != "" {
+		attrs = append(attrs, "pattern: "+s.Pattern)
+	}
+
+	switch {
+	case len(attrs) == 0:
+		return s.Description
+	case s.Description == "":
+		return strings.Join(attrs, ", ")
+	default:
+		return s.Description + " (" + strings.Join(attrs, ", ") + ")"
+	}
+}
+
+// ValidateEnv checks decrypted variables against the project schema and
+// returns one message per problem, sorted by key
+func (p *Project) ValidateEnv(vars []parser.EnvVar) []string {
+	values := make(map[string]string, len(vars))
+	for _, v := range vars {
+		values[v.Key] = v.Value
+	}
+
+	var problems []string
+	for key, s := range p.Config.Schema {
+		value, ok := values[key]
+		if !ok || value == "" {
+			if s.Required {
+				problems = append(problems, fmt.Sprintf("%s: required but missing", key))
+			}
+			continue
+		}
+		if err := s.Validate(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// EnvExample builds a .env.example from the keys and comments of .env files
+// (values are dropped), annotated with the project schema. Keys declared in
+// the schema but missing from the files are appended at the end.
+func (p *Project) EnvExample(sources []string, files [][]parser.EnvEntry) []byte {
+	var sb strings.Builder
+	sb.WriteString("# Generated by podx env example from " + strings.Join(sources, ", ") + "\n")
+	sb.WriteString("# Copy to .env and fill in the values\n")
+
+	seen := make(map[string]bool)
+	writeKey := func(key string) {
+		s, ok := p.Config.Schema[key]
+		if ok {
+			if c := s.comment(); c != "" {
+				sb.WriteString("# " + c + "\n")
+			}
+		}
+		sb.WriteString(key + "=" + exampleValue(s.Placeholder()) + "\n")
+	}
+
+	for _, entries := range files {
+		sb.WriteString("\n")
+		// Blank lines are written only before further output, so runs of
+		// them, and those before dropped lines, collapse into one
+		started, blank := false, false
+		for _, entry := range entries {
+			comment := strings.TrimSpace(entry.Comment)
+			key := strings.TrimSpace(strings.TrimPrefix(entry.Key, "export "))
+			switch {
+			// Drop the metadata of password-encrypted files (salt, KDF parameters)
+			case entry.IsComment && strings.HasPrefix(comment, "# IRONVAULT_"):
+				continue
+			case entry.IsComment && comment == "":
+				blank = started
+				continue
+			case !entry.IsComment && seen[key]:
+				continue
+			}
+
+			if blank {
+				sb.WriteString("\n")
+			}
+			started, blank = true, false
+			if entry.IsComment {
+				sb.WriteString(comment + "\n")
+			} else {
+				seen[key] = true
+				writeKey(key)
+			}
+		}
+	}
+
+	var missing []string
+	for key := range p.Config.Schema {
+		if !seen[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		sb.WriteString("\n# Declared in .podx.yaml\n")
+		for _, key := range missing {
+			writeKey(key)
+		}
+	}
+
+	return []byte(strings.TrimRight(sb.String(), "\n") + "\n")
+}
+
+// exampleValue quotes placeholders that would not survive as bare .env values
+func exampleValue(value string) string {
+	if strings.ContainsAny(value, " #\"'\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,108 @@
+package project
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hades/podx/parser"
+)
+
+func TestKeySchemaValidate(t *testing.T) {
+	tests := []struct {
+		schema KeySchema
+		value  string
+		ok     bool
+	}{
+		{KeySchema{}, "anything goes", true},
+		{KeySchema{Type: "string"}, "", true},
+		{KeySchema{Type: "int"}, "-42", true},
+		{KeySchema{Type: "int"}, "4.2", false},
+		{KeySchema{Type: "INT"}, "7", true},
+		{KeySchema{Type: "bool"}, "Yes", true},
+		{KeySchema{Type: "bool"}, "maybe", false},
+		{KeySchema{Type: "url"}, "postgres://db:5432/app", true},
+		{KeySchema{Type: "url"}, "mailto:ops@example.com", true},
+		{KeySchema{Type: "url"}, "localhost", false},
+		{KeySchema{Type: "url"}, "/relative/path", false},
+		{KeySchema{Type: "uuid"}, "x", false},
+		{KeySchema{Pattern: `sk_(live|test)_\w+`}, "sk_live_abc", true},
+		{KeySchema{Pattern: `sk_(live|test)_\w+`}, "xsk_live_abc", false},
+		{KeySchema{Pattern: `a|b`}, "ab", false},
+		{KeySchema{Pattern: `(`}, "x", false},
+		{KeySchema{Enum: []string{"dev", "prod"}}, "prod", true},
+		{KeySchema{Enum: []string{"dev", "prod"}}, "Prod", false},
+		{KeySchema{Type: "int", Enum: []string{"1", "2"}}, "3", false},
+	}
+	for _, tt := range tests {
+		err := tt.schema.Validate(tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("%+v.Validate(%q) = %v, want ok = %v", tt.schema, tt.value, err, tt.ok)
+		}
+		if err != nil && len(tt.value) > 3 && strings.Contains(err.Error(), tt.value) {
+			t.Errorf("error leaks the value: %v", err)
+		}
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	p := &Project{Config: &Config{Schema: map[string]KeySchema{
+		"DB_URL":   {Required: true, Type: "url"},
+		"PORT":     {Type: "int"},
+		"API_KEY":  {Required: true},
+		"LOG":      {Enum: []string{"debug", "info"}},
+		"OPTIONAL": {Type: "int"},
+	}}}
+	vars := []parser.EnvVar{
+		{Key: "DB_URL", Value: "not a url"},
+		{Key: "PORT", Value: "80a"},
+		{Key: "API_KEY", Value: ""},
+		{Key: "LOG", Value: "info"},
+		{Key: "EXTRA", Value: "ignored"},
+	}
+
+	got := p.ValidateEnv(vars)
+	want := []string{
+		"API_KEY: required but missing",
+		"DB_URL: expected a URL with scheme and host",
+		"PORT: expected an integer",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ValidateEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestEnvExample(t *testing.T) {
+	entries, err := parser.ParseEnv([]byte("# IRONVAULT_SALT=c2FsdA==\n# IRONVAULT_KDF=moderate\n\n# Database\nDB_URL=ENC[aes256gcm:abc]\nexport PORT=5432\n\n\nDB_URL=dup\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Project{Config: &Config{Schema: map[string]KeySchema{
+		"DB_URL":   {Description: "Postgres DSN", Required: true, Type: "url"},
+		"PORT":     {Type: "int", Default: "5432"},
+		"MODE":     {Enum: []string{"dev", "prod"}},
+		"GREETING": {Default: "hello world"},
+	}}}
+
+	got := string(p.EnvExample([]string{".env.podx"}, [][]parser.EnvEntry{entries}))
+	want := `# Generated by podx env example from .env.podx
+# Copy to .env and fill in the values
+
+# Database
+# Postgres DSN (required, url)
+DB_URL=https://example.com
+# int
+PORT=5432
+
+# Declared in .podx.yaml
+GREETING="hello world"
+# one of: dev, prod
+MODE=dev
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if strings.Contains(got, "IRONVAULT") || strings.Contains(got, "abc") {
+		t.Errorf("metadata or values leaked:\n%s", got)
+	}
+}